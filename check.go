@@ -0,0 +1,149 @@
+// ABOUTME: One-line status mode for canvas-report (--check).
+// ABOUTME: Prints a compact summary per student and exits with a code scripts, cron and prompts can branch on.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// checkStatus is one student's line in --check mode.
+type checkStatus struct {
+	Name        string      `json:"name"`
+	Missing     int         `json:"missing"`
+	DueToday    int         `json:"due_today"`
+	DueTomorrow int         `json:"due_tomorrow"`
+	LowestGrade *jsonLowest `json:"lowest_grade,omitempty"`
+	Problems    int         `json:"problems"`
+	Status      string      `json:"status"` // "clear", "due_soon" or "missing"
+	code        int
+}
+
+type jsonLowest struct {
+	Course  string  `json:"course"`
+	Percent float64 `json:"percent"`
+}
+
+// Check statuses, from best to worst, and their exit codes.
+const (
+	statusClear   = "clear"
+	statusDueSoon = "due_soon"
+	statusMissing = "missing"
+)
+
+var checkExitCodes = map[string]int{
+	statusClear:   exitOK,
+	statusDueSoon: exitDueSoon,
+	statusMissing: exitMissing,
+}
+
+// newCheckStatus summarizes a student's report. Due tomorrow means the next
+// school day, as in the DUE TODAY/TOMORROW section.
+func newCheckStatus(data studentData) checkStatus {
+	st := checkStatus{Name: data.name, Problems: len(data.problems), Status: statusClear}
+
+	if sec := data.section(sectionMissing); sec != nil {
+		st.Missing = len(sec.assignments)
+	}
+	if sec := data.section(sectionUpcoming); sec != nil {
+		today := truncateToDay(time.Now())
+		for _, a := range sec.assignments {
+			if isCompleted(a.Submission) {
+				continue
+			}
+			if truncateToDay(a.DueAt.Local()).Equal(today) {
+				st.DueToday++
+			} else {
+				st.DueTomorrow++
+			}
+		}
+	}
+	for _, pg := range data.grades {
+		for _, g := range pg.grades {
+			if st.LowestGrade == nil || g.Percent < st.LowestGrade.Percent {
+				st.LowestGrade = &jsonLowest{Course: g.CourseName, Percent: g.Percent}
+			}
+		}
+	}
+
+	switch {
+	case st.Missing > 0:
+		st.Status = statusMissing
+	case st.DueToday+st.DueTomorrow > 0:
+		st.Status = statusDueSoon
+	}
+	st.code = checkExitCodes[st.Status]
+	return st
+}
+
+// line formats the status as "Jane: 3 missing, 1 due today, lowest grade
+// 82% Pre-Algebra", colored for the terminal unless plain.
+func (st checkStatus) line(plain bool) string {
+	paint := func(attr color.Attribute, text string) string {
+		if plain {
+			return text
+		}
+		return color.New(attr).Sprint(text)
+	}
+
+	var parts []string
+	if st.Missing > 0 {
+		parts = append(parts, paint(color.FgRed, fmt.Sprintf("%d missing", st.Missing)))
+	}
+	if st.DueToday > 0 {
+		parts = append(parts, paint(color.FgYellow, fmt.Sprintf("%d due today", st.DueToday)))
+	}
+	if st.DueTomorrow > 0 {
+		parts = append(parts, paint(color.FgYellow, fmt.Sprintf("%d due tomorrow", st.DueTomorrow)))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, paint(color.FgGreen, "all clear"))
+	}
+	if st.LowestGrade != nil {
+		parts = append(parts, fmt.Sprintf("lowest grade %.0f%% %s", st.LowestGrade.Percent, st.LowestGrade.Course))
+	}
+	if st.Problems > 0 {
+		parts = append(parts, paint(color.FgYellow, fmt.Sprintf("%d data problem(s)", st.Problems)))
+	}
+	return st.Name + ": " + strings.Join(parts, ", ")
+}
+
+// renderCheck prints one line per student and returns an exitCodeError
+// carrying the worst status: missing work, then work due soon, then (with
+// --strict) incomplete data. All clear returns nil.
+func (r *Report) renderCheck(students []studentData) error {
+	var statuses []checkStatus
+	worst, problems := exitOK, 0
+	for _, data := range students {
+		st := newCheckStatus(data)
+		statuses = append(statuses, st)
+		worst = max(worst, st.code)
+		problems += st.Problems
+	}
+
+	if r.format == "json" {
+		if statuses == nil {
+			statuses = []checkStatus{}
+		}
+		if err := writeJSON(os.Stdout, statuses); err != nil {
+			return err
+		}
+	} else {
+		if len(students) == 0 {
+			fmt.Println(noStudentsMessage)
+		}
+		for _, st := range statuses {
+			fmt.Println(st.line(false))
+		}
+	}
+
+	if worst != exitOK {
+		return &exitCodeError{code: worst}
+	}
+	return r.checkStrict(problems)
+}
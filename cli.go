@@ -0,0 +1,789 @@
+// ABOUTME: Command-line interface for canvas-report.
+// ABOUTME: Defines subcommands, global flags, help output, and dispatch to the report.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+const (
+	exitOK           = 0
+	exitError        = 1
+	exitUsage        = 2
+	exitDataProblems = 3 // --strict and some data couldn't be fetched
+	exitDueSoon      = 4 // --check and work is due today or tomorrow
+	exitMissing      = 5 // --check and work is missing
+)
+
+// exitCodeError makes the command exit with a specific code. A nil err
+// exits quietly, for statuses the output already explains.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+var outputFormats = []string{"text", "json", "markdown"}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ", ") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+type cli struct {
+	// Global flags, accepted before or after the command name
+	format          string
+	configPath      string
+	profile         string
+	baseURL         string
+	token           string
+	noColor         bool
+	students        stringList
+	excludeStudents stringList
+	courses         stringList
+	excludeCourses  stringList
+	verbose         bool
+	trace           bool
+	logFile         string
+
+	tracer *tracer
+
+	// Command flags
+	showAll       bool
+	strict        bool
+	check         bool
+	compact       bool
+	exportGrades  bool
+	tsv           bool
+	listStatus    stringList
+	listFrom      string
+	listTo        string
+	listCategory  stringList
+	listSort      string
+	planDays      int
+	workloadWeeks int
+	loginPort     int
+	dryRun        bool
+	summary       bool
+	webhook       string
+	emailTo       string
+	serveAddr     string
+	serveRefresh  time.Duration
+	servePassword string
+}
+
+type command struct {
+	name    string
+	args    string // Positional arguments shown in the usage line
+	summary string
+	flags   func(c *cli, fs *flag.FlagSet)
+	run     func(c *cli, args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because help and completion refer back to the table
+	commands = []command{
+		{
+			name:    "report",
+			summary: "Missing work, what's due soon, the week ahead and grades (default)",
+			flags:   reportFlags,
+			run:     runSections(nil),
+		},
+		{
+			name:    "missing",
+			summary: "Only missing work",
+			flags:   reportFlags,
+			run:     runSections([]string{sectionMissing, sectionUnconfirmed}),
+		},
+		{
+			name:    "week",
+			summary: "Work due today, tomorrow and through the end of the school week",
+			flags:   sectionFlags,
+			run:     runSections([]string{sectionUpcoming, sectionWeekAhead}),
+		},
+		{
+			name:    "grades",
+			summary: "Current grades for each course",
+			flags:   sectionFlags,
+			run:     runSections([]string{sectionGrades}),
+		},
+		{
+			name:    "planner",
+			summary: "Canvas planner for the next two weeks, including undated work",
+			flags:   strictFlag,
+			run:     runPlanner,
+		},
+		{
+			name:    "list",
+			summary: "Every assignment, filtered by status, dates, course or category",
+			flags:   listFlags,
+			run:     runList,
+		},
+		{
+			name:    "plan",
+			summary: "Missing and upcoming work ranked by what it's worth, with each course's recovery",
+			flags:   planFlags,
+			run:     runPlan,
+		},
+		{
+			name:    "workload",
+			summary: "Heatmap of work due each school day over the next few weeks",
+			flags:   workloadFlags,
+			run:     runWorkload,
+		},
+		{
+			name:    "export",
+			summary: "Every assignment, or every grade, as CSV or TSV for spreadsheets",
+			flags:   exportFlags,
+			run:     runExport,
+		},
+		{
+			name:    "notify",
+			summary: "Send newly missing work and grade drops to webhooks",
+			flags:   notifyFlags,
+			run:     runNotify,
+		},
+		{
+			name:    "email",
+			summary: "Email the report to configured recipients",
+			flags:   emailFlags,
+			run:     runEmail,
+		},
+		{
+			name:    "serve",
+			summary: "Serve the report as a web dashboard and JSON API",
+			flags:   serveFlags,
+			run:     runServe,
+		},
+		{
+			name:    "tui",
+			summary: "Browse the report full-screen and open assignment details",
+			flags:   allFlag,
+			run:     runTUI,
+		},
+		{
+			name:    "students",
+			summary: "List observed students",
+			run:     runStudents,
+		},
+		{
+			name:    "courses",
+			summary: "List each student's courses and whether filters include them",
+			run:     runCourses,
+		},
+		{
+			name:    "login",
+			summary: "Sign in to Canvas with OAuth instead of an access token",
+			flags:   loginFlags,
+			run:     runLogin,
+		},
+		{
+			name:    "config",
+			args:    "show|set|test|edit",
+			summary: "Show, change, test or edit the configuration",
+			run:     runConfig,
+		},
+		{
+			name:    "doctor",
+			summary: "Check the URL, token, observer links and per-course access",
+			run:     runDoctor,
+		},
+		{
+			name:    "completion",
+			args:    "bash|zsh|fish",
+			summary: "Print a shell completion script",
+			run:     runCompletion,
+		},
+		{
+			name:    "version",
+			summary: "Print the version",
+			run:     runVersion,
+		},
+		{
+			name:    "help",
+			args:    "[command]",
+			summary: "Show help for a command",
+			run:     runHelp,
+		},
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func allFlag(c *cli, fs *flag.FlagSet) {
+	fs.BoolVar(&c.showAll, "all", false, "include missing work older than the missing cutoff (report.missing_cutoff, default 30d)")
+}
+
+func strictFlag(c *cli, fs *flag.FlagSet) {
+	fs.BoolVar(&c.strict, "strict", false, fmt.Sprintf("exit with status %d if any data couldn't be fetched", exitDataProblems))
+}
+
+func checkFlag(c *cli, fs *flag.FlagSet) {
+	fs.BoolVar(&c.check, "check", false, fmt.Sprintf("print one status line per student; exit %d if work is due soon, %d if any is missing", exitDueSoon, exitMissing))
+}
+
+func compactFlag(c *cli, fs *flag.FlagSet) {
+	fs.BoolVar(&c.compact, "compact", false, "with --format markdown, short bullet lists instead of tables, for chat apps")
+}
+
+// sectionFlags are the flags shared by every report-style command.
+func sectionFlags(c *cli, fs *flag.FlagSet) {
+	strictFlag(c, fs)
+	checkFlag(c, fs)
+	compactFlag(c, fs)
+}
+
+func reportFlags(c *cli, fs *flag.FlagSet) {
+	allFlag(c, fs)
+	sectionFlags(c, fs)
+}
+
+// registerGlobal adds the global flags to fs. The current values become the
+// defaults so flags parsed before the command name survive re-registration.
+func (c *cli) registerGlobal(fs *flag.FlagSet) {
+	fs.StringVar(&c.format, "format", c.format, "output format: "+strings.Join(outputFormats, ", "))
+	fs.StringVar(&c.configPath, "config", c.configPath, "config file `path`, overriding "+envConfig+" (default ~/.config/canvas-report/config.yaml)")
+	fs.StringVar(&c.profile, "profile", c.profile, "Canvas profile to use, or \"all\" to merge every profile")
+	fs.StringVar(&c.baseURL, "base-url", c.baseURL, "Canvas `URL`, overriding the config and "+envBaseURL)
+	fs.StringVar(&c.token, "token", c.token, "Canvas API `token`, overriding the config and "+envToken+" (visible to other users in ps)")
+	fs.BoolVar(&c.noColor, "no-color", c.noColor, "disable colored output")
+	fs.Var(&c.students, "student", "only include students matching `name` (repeatable)")
+	fs.Var(&c.excludeStudents, "exclude-student", "skip students matching `name` (repeatable)")
+	fs.Var(&c.courses, "course", "only include courses matching `pattern` (repeatable)")
+	fs.Var(&c.excludeCourses, "exclude-course", "skip courses matching `pattern` (repeatable)")
+	fs.BoolVar(&c.verbose, "verbose", c.verbose, "log each Canvas request and print a timing summary")
+	fs.BoolVar(&c.trace, "trace", c.trace, "like --verbose, plus query strings and response headers")
+	fs.StringVar(&c.logFile, "log-file", c.logFile, "write --verbose/--trace output to `path` instead of stderr")
+}
+
+func (c *cli) commandFlags(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	c.registerGlobal(fs)
+	if cmd.flags != nil {
+		cmd.flags(c, fs)
+	}
+	return fs
+}
+
+// run parses args, dispatches to the command and returns the exit code.
+func run(args []string) int {
+	c := &cli{format: "text"}
+
+	top := flag.NewFlagSet("canvas-report", flag.ContinueOnError)
+	top.SetOutput(io.Discard)
+	c.registerGlobal(top)
+	if err := top.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stdout)
+			return exitOK
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'canvas-report --help' for usage.\n", err)
+		return exitUsage
+	}
+
+	rest := top.Args()
+	name := "report"
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\nRun 'canvas-report --help' for usage.\n", name)
+		return exitUsage
+	}
+
+	fs := c.commandFlags(cmd)
+	if err := fs.Parse(rest); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printCommandUsage(os.Stdout, cmd)
+			return exitOK
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\nRun 'canvas-report %s --help' for usage.\n", err, cmd.name)
+		return exitUsage
+	}
+
+	if !validFormat(c.format) {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want %s)\n", c.format, strings.Join(outputFormats, ", "))
+		return exitUsage
+	}
+	if c.noColor {
+		color.NoColor = true
+	}
+
+	if c.verbose || c.trace {
+		w := io.Writer(os.Stderr)
+		if c.logFile != "" {
+			f, err := os.Create(c.logFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitError
+			}
+			defer f.Close()
+			w = f
+		}
+		c.tracer = newTracer(w, c.trace)
+		defer c.tracer.printSummary()
+	}
+
+	if err := cmd.run(c, fs.Args()); err != nil {
+		var coded *exitCodeError
+		if errors.As(err, &coded) {
+			if coded.err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			return coded.code
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func validFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Environment variables that override the config file.
+const (
+	envConfig  = "CANVAS_REPORT_CONFIG"
+	envBaseURL = "CANVAS_REPORT_BASE_URL"
+	envToken   = "CANVAS_REPORT_TOKEN"
+)
+
+// flagOrEnv returns the flag value if set, otherwise the environment variable.
+func flagOrEnv(flagValue, env string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return os.Getenv(env)
+}
+
+func (c *cli) resolvedConfigPath() (string, error) {
+	if path := flagOrEnv(c.configPath, envConfig); path != "" {
+		return path, nil
+	}
+	return defaultConfigPath()
+}
+
+// loadConfig reads the config file and applies flag and environment
+// overrides, in that order of precedence: flags, then environment, then file.
+// With no config file it runs interactive setup only when stdin is a
+// terminal; otherwise it fails rather than waiting on a prompt.
+func (c *cli) loadConfig() (*Config, error) {
+	path, err := c.resolvedConfigPath()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine config path: %w", err)
+	}
+
+	baseURL := flagOrEnv(c.baseURL, envBaseURL)
+	token := flagOrEnv(c.token, envToken)
+
+	cfg, err := loadConfig(path)
+	switch {
+	case err == nil:
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("loading config: %w", err)
+	case baseURL != "" && token != "":
+		cfg = &Config{}
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		return nil, fmt.Errorf("no config at %s; set %s and %s (or --base-url and --token), or run canvas-report interactively to set one up",
+			path, envBaseURL, envToken)
+	default:
+		cfg, err = runSetup(path)
+		if err != nil {
+			return nil, fmt.Errorf("setup failed: %w", err)
+		}
+	}
+
+	if baseURL != "" || token != "" {
+		if c.profile == allProfiles {
+			return nil, fmt.Errorf("base URL and token overrides can't be combined with --profile all")
+		}
+		p, err := cfg.GetProfile(c.profile)
+		if err != nil {
+			return nil, err
+		}
+		if baseURL != "" {
+			p.BaseURL = strings.TrimSuffix(baseURL, "/")
+		}
+		if token != "" {
+			p.AccessToken = token
+		}
+	}
+
+	return cfg, nil
+}
+
+// newReports builds one report per selected profile, each with its own
+// Canvas client but sharing filters and display settings.
+func (c *cli) newReports(cfg *Config, sections []string) ([]*Report, error) {
+	names, err := cfg.SelectProfiles(c.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Report.validate(); err != nil {
+		return nil, err
+	}
+
+	filters := cfg.Filters()
+	filters.Students = filters.Students.Merge(Filter{Include: c.students, Exclude: c.excludeStudents})
+	filters.Courses = filters.Courses.Merge(Filter{Include: c.courses, Exclude: c.excludeCourses})
+
+	var reports []*Report
+	for _, name := range names {
+		client, err := c.newClient(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, NewReport(client, ReportOptions{
+			Profile:     name,
+			ShowAll:     c.showAll,
+			Strict:      c.strict,
+			Check:       c.check,
+			Compact:     c.compact,
+			Filters:     filters,
+			CourseNames: cfg.CourseNames,
+			Sections:    sections,
+			Layout:      cfg.Report,
+			Format:      c.format,
+		}))
+	}
+	return reports, nil
+}
+
+// newClient returns a Canvas client for the named profile. Profiles signed
+// in with OAuth refresh their access token when it expires and save the new
+// one to the config file, unless a token was passed with --token or the
+// environment.
+func (c *cli) newClient(cfg *Config, name string) (*CanvasClient, error) {
+	p, err := cfg.GetProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	if p.BaseURL == "" || p.AccessToken == "" {
+		return nil, fmt.Errorf("profile %q is missing base_url or access_token", name)
+	}
+
+	client := NewCanvasClient(p.BaseURL, p.AccessToken)
+	client.tracer = c.tracer
+	if p.RefreshToken != "" && p.ClientID != "" && flagOrEnv(c.token, envToken) == "" {
+		client.refresh = func() (string, error) {
+			token, err := refreshAccessToken(p)
+			if err != nil {
+				return "", err
+			}
+			p.AccessToken = token.AccessToken
+			if token.RefreshToken != "" {
+				p.RefreshToken = token.RefreshToken
+			}
+			if err := c.saveRefreshedToken(name, token); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not save refreshed token: %v\n", err)
+			}
+			return token.AccessToken, nil
+		}
+	}
+	return client, nil
+}
+
+// saveRefreshedToken writes a refreshed token to the named profile in the
+// config file. It starts from the file rather than the loaded config, so
+// --base-url and environment overrides never get saved.
+func (c *cli) saveRefreshedToken(name string, token *tokenResponse) error {
+	path, err := c.resolvedConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	p, err := cfg.GetProfile(name)
+	if err != nil {
+		return err
+	}
+	p.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		p.RefreshToken = token.RefreshToken
+	}
+	return saveConfig(path, cfg)
+}
+
+func (c *cli) loadReports(args []string, sections []string) ([]*Report, error) {
+	if err := noArgs(args); err != nil {
+		return nil, err
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return c.newReports(cfg, sections)
+}
+
+func runSections(sections []string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		if c.compact && c.format != "markdown" {
+			return &exitCodeError{code: exitUsage, err: errors.New("--compact needs --format markdown")}
+		}
+		reports, err := c.loadReports(args, sections)
+		if err != nil {
+			return err
+		}
+		return generateReports(reports)
+	}
+}
+
+func runPlanner(c *cli, args []string) error {
+	reports, err := c.loadReports(args, nil)
+	if err != nil {
+		return err
+	}
+	return generatePlanners(reports)
+}
+
+func runStudents(c *cli, args []string) error {
+	reports, err := c.loadReports(args, nil)
+	if err != nil {
+		return err
+	}
+
+	type studentJSON struct {
+		Profile  string `json:"profile"`
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Included bool   `json:"included"`
+	}
+	var out []studentJSON
+	for _, r := range reports {
+		observees, err := r.client.Observees()
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.profile, err)
+		}
+		for _, o := range observees {
+			out = append(out, studentJSON{Profile: r.profile, ID: o.ID, Name: studentName(o), Included: r.filters.includeStudent(o)})
+		}
+	}
+
+	if c.format == "json" {
+		return writeJSON(os.Stdout, out)
+	}
+	for _, s := range out {
+		line := fmt.Sprintf("%-10d %s", s.ID, s.Name)
+		if len(reports) > 1 {
+			line = fmt.Sprintf("%-12s %s", s.Profile, line)
+		}
+		if !s.Included {
+			line += color.New(color.Faint).Sprint("  (excluded)")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func runCourses(c *cli, args []string) error {
+	reports, err := c.loadReports(args, nil)
+	if err != nil {
+		return err
+	}
+
+	type courseJSON struct {
+		ID          int    `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		Included    bool   `json:"included"`
+	}
+	type studentCoursesJSON struct {
+		Student string       `json:"student"`
+		Courses []courseJSON `json:"courses"`
+	}
+
+	var out []studentCoursesJSON
+	for _, r := range reports {
+		observees, err := r.observees()
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.profile, err)
+		}
+		for _, o := range observees {
+			courses, err := r.client.Courses(o.ID)
+			if err != nil {
+				return err
+			}
+			sc := studentCoursesJSON{Student: studentName(o), Courses: []courseJSON{}}
+			for _, course := range courses {
+				name := course.Name
+				if course.OriginalName != "" {
+					name = course.OriginalName
+				}
+				sc.Courses = append(sc.Courses, courseJSON{
+					ID:          course.ID,
+					Name:        name,
+					DisplayName: r.names.name(course),
+					Included:    r.filters.includeCourse(o, course),
+				})
+			}
+			out = append(out, sc)
+		}
+		if r.names.err != nil && !r.quiet {
+			fmt.Fprintf(os.Stderr, "  warning: course nicknames: %v\n", r.names.err)
+		}
+	}
+
+	if c.format == "json" {
+		return writeJSON(os.Stdout, out)
+	}
+
+	bold := color.New(color.Bold)
+	dim := color.New(color.Faint)
+	for i, sc := range out {
+		if i > 0 {
+			fmt.Println()
+		}
+		bold.Println(sc.Student)
+		for _, course := range sc.Courses {
+			line := fmt.Sprintf("  %-10d %s", course.ID, course.DisplayName)
+			if course.DisplayName != course.Name {
+				line += dim.Sprintf("  (%s)", course.Name)
+			}
+			if !course.Included {
+				line += dim.Sprint("  (excluded)")
+			}
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+func runVersion(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	v := version
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version
+	}
+	fmt.Printf("canvas-report %s\n", v)
+	return nil
+}
+
+func runHelp(c *cli, args []string) error {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return nil
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	printCommandUsage(os.Stdout, cmd)
+	return nil
+}
+
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected argument %q", args[0])
+	}
+	return nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "canvas-report shows parents what's missing, due soon and graded in Canvas.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  canvas-report [global flags] [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	(&cli{format: "text"}).registerGlobal(fs)
+	printFlags(w, fs)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'canvas-report <command> --help' for a command's flags.")
+}
+
+func printCommandUsage(w io.Writer, cmd *command) {
+	usage := "canvas-report " + cmd.name + " [flags]"
+	if cmd.args != "" {
+		usage += " " + cmd.args
+	}
+	fmt.Fprintln(w, cmd.summary)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintf(w, "  %s\n", usage)
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	defaults := &cli{format: "text"}
+	if cmd.flags != nil {
+		cmd.flags(defaults, fs)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		printFlags(w, fs)
+	}
+
+	global := flag.NewFlagSet("", flag.ContinueOnError)
+	defaults.registerGlobal(global)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	printFlags(w, global)
+}
+
+// printFlags lists flags GNU-style ("--name value").
+func printFlags(w io.Writer, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		valueName, usage := flag.UnquoteUsage(f)
+		left := "--" + f.Name
+		if valueName != "" {
+			left += " " + valueName
+		}
+		if f.DefValue != "" && f.DefValue != "false" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		fmt.Fprintf(w, "  %-28s %s\n", left, usage)
+	})
+}
@@ -0,0 +1,77 @@
+// ABOUTME: Tests for canvas-report command line handling.
+// ABOUTME: Checks a refreshed OAuth token is saved without the flag and environment overrides.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRefreshSavesOnlyTokens(t *testing.T) {
+	canvas := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/login/oauth2/token" || r.FormValue("refresh_token") != "refresh-old" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "tok-new", "refresh_token": "refresh-new"}`))
+	}))
+	defer canvas.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `base_url: https://school.instructure.com
+access_token: tok-old
+refresh_token: refresh-old
+client_id: "10000"
+token_storage: plaintext
+`
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envBaseURL, canvas.URL)
+	t.Setenv(envToken, "")
+
+	c := &cli{configPath: path}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := c.newClient(cfg, defaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.refresh == nil {
+		t.Fatal("no refresh for an OAuth profile")
+	}
+	if token, err := client.refresh(); err != nil || token != "tok-new" {
+		t.Fatalf("refresh = %q, %v; want tok-new", token, err)
+	}
+
+	saved, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.BaseURL != "https://school.instructure.com" {
+		t.Errorf("saved base_url = %q, want the file's, not the %s override", saved.BaseURL, envBaseURL)
+	}
+	if saved.AccessToken != "tok-new" || saved.RefreshToken != "refresh-new" {
+		t.Errorf("saved tokens = %q, %q; want tok-new, refresh-new", saved.AccessToken, saved.RefreshToken)
+	}
+
+	// An explicit token is used as given, never refreshed over
+	t.Setenv(envToken, "tok-env")
+	cfg, err = c.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client, err = c.newClient(cfg, defaultProfile); err != nil {
+		t.Fatal(err)
+	}
+	if client.refresh != nil {
+		t.Errorf("refresh set despite the %s override", envToken)
+	}
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,6 +20,12 @@ type CanvasClient struct {
 	baseURL     string
 	accessToken string
 	httpClient  *http.Client
+
+	// refresh, when set, gets a new access token after a 401
+	refresh func() (string, error)
+	mu      sync.Mutex
+
+	tracer *tracer // Logs requests for --verbose/--trace; nil otherwise
 }
 
 type Observee struct {
@@ -27,15 +35,31 @@ type Observee struct {
 }
 
 type Course struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	OriginalName string `json:"original_name"` // Set when Name is the caller's nickname
+}
+
+type CourseNickname struct {
+	CourseID int    `json:"course_id"`
+	Name     string `json:"name"`
+	Nickname string `json:"nickname"`
 }
 
 type Assignment struct {
-	ID             int       `json:"id"`
-	Name           string    `json:"name"`
-	DueAt          *time.Time `json:"due_at"`
-	PointsPossible *float64  `json:"points_possible"`
+	ID              int              `json:"id"`
+	CourseID        int              `json:"course_id"`
+	Name            string           `json:"name"`
+	DueAt           *time.Time       `json:"due_at"`
+	PointsPossible  *float64         `json:"points_possible"`
+	PlannerOverride *PlannerOverride `json:"planner_override"`
+	Submission      *Submission      `json:"submission"`
+	HTMLURL         string           `json:"html_url"`
+}
+
+type PlannerOverride struct {
+	MarkedComplete bool `json:"marked_complete"`
+	Dismissed      bool `json:"dismissed"`
 }
 
 type Submission struct {
@@ -45,9 +69,66 @@ type Submission struct {
 	Score                         *float64   `json:"score"`
 	Missing                       bool       `json:"missing"`
 	Excused                       bool       `json:"excused"`
+	Late                          bool       `json:"late"`
+	SecondsLate                   int64      `json:"seconds_late"`
+	PointsDeducted                *float64   `json:"points_deducted"`
+	LatePolicyStatus              string     `json:"late_policy_status"` // late, missing, extended or none when a teacher set it
 	GradeMatchesCurrentSubmission *bool      `json:"grade_matches_current_submission"`
 }
 
+// LatePolicy is how a course deducts points for late work.
+type LatePolicy struct {
+	DeductionEnabled      bool    `json:"late_submission_deduction_enabled"`
+	Deduction             float64 `json:"late_submission_deduction"` // Percent of the points possible per interval
+	Interval              string  `json:"late_submission_interval"`  // day or hour
+	MinimumPercentEnabled bool    `json:"late_submission_minimum_percent_enabled"`
+	MinimumPercent        float64 `json:"late_submission_minimum_percent"`
+}
+
+type latePolicyResponse struct {
+	LatePolicy LatePolicy `json:"late_policy"`
+}
+
+type PlannerItem struct {
+	CourseID        int              `json:"course_id"`
+	ContextName     string           `json:"context_name"`
+	PlannableID     int              `json:"plannable_id"`
+	PlannableType   string           `json:"plannable_type"`
+	PlannableDate   *time.Time       `json:"plannable_date"`
+	Plannable       Plannable        `json:"plannable"`
+	Submissions     json.RawMessage  `json:"submissions"` // false, or a PlannerSubmission object
+	PlannerOverride *PlannerOverride `json:"planner_override"`
+	HTMLURL         string           `json:"html_url"`
+}
+
+type Plannable struct {
+	Title          string     `json:"title"`
+	DueAt          *time.Time `json:"due_at"`
+	TodoDate       *time.Time `json:"todo_date"`
+	PointsPossible *float64   `json:"points_possible"`
+}
+
+type PlannerSubmission struct {
+	Submitted bool `json:"submitted"`
+	Excused   bool `json:"excused"`
+	Graded    bool `json:"graded"`
+	Late      bool `json:"late"`
+	Missing   bool `json:"missing"`
+}
+
+// SubmissionState decodes the planner item's submission summary. Items that
+// can't be submitted (pages, notes) report false, which yields nil.
+func (p PlannerItem) SubmissionState() *PlannerSubmission {
+	if len(p.Submissions) == 0 || p.Submissions[0] != '{' {
+		return nil
+	}
+	var sub PlannerSubmission
+	if err := json.Unmarshal(p.Submissions, &sub); err != nil {
+		return nil
+	}
+	return &sub
+}
+
 type GradingPeriod struct {
 	ID        any        `json:"id"`
 	Title     string     `json:"title"`
@@ -76,6 +157,55 @@ type AssignmentInGroup struct {
 	DueAt          *time.Time `json:"due_at"`
 }
 
+// AssignmentDetail is the full assignment, for the detail view.
+type AssignmentDetail struct {
+	ID             int               `json:"id"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description"` // HTML
+	HTMLURL        string            `json:"html_url"`
+	PointsPossible *float64          `json:"points_possible"`
+	Rubric         []RubricCriterion `json:"rubric"`
+}
+
+type RubricCriterion struct {
+	ID          string         `json:"id"`
+	Description string         `json:"description"`
+	Points      float64        `json:"points"`
+	Ratings     []RubricRating `json:"ratings"`
+}
+
+type RubricRating struct {
+	Description string  `json:"description"`
+	Points      float64 `json:"points"`
+}
+
+// SubmissionDetail is a submission with its history and feedback.
+type SubmissionDetail struct {
+	Attempt            *int                        `json:"attempt"`
+	SubmittedAt        *time.Time                  `json:"submitted_at"`
+	GradedAt           *time.Time                  `json:"graded_at"`
+	Score              *float64                    `json:"score"`
+	Grade              string                      `json:"grade"`
+	Late               bool                        `json:"late"`
+	Missing            bool                        `json:"missing"`
+	Excused            bool                        `json:"excused"`
+	WorkflowState      string                      `json:"workflow_state"`
+	SubmissionHistory  []SubmissionDetail          `json:"submission_history"`
+	SubmissionComments []SubmissionComment         `json:"submission_comments"`
+	RubricAssessment   map[string]RubricAssessment `json:"rubric_assessment"` // Keyed by criterion ID
+}
+
+type SubmissionComment struct {
+	AuthorName string    `json:"author_name"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type RubricAssessment struct {
+	Points   *float64 `json:"points"`
+	Comments string   `json:"comments"`
+}
+
 func NewCanvasClient(baseURL, accessToken string) *CanvasClient {
 	return &CanvasClient{
 		baseURL:     strings.TrimSuffix(baseURL, "/"),
@@ -93,6 +223,11 @@ func (c *CanvasClient) Courses(userID int) ([]Course, error) {
 	return getPaginated[Course](c, fmt.Sprintf("/api/v1/users/%d/courses", userID), params)
 }
 
+// CourseNicknames returns the nicknames the signed-in user has given courses.
+func (c *CanvasClient) CourseNicknames() ([]CourseNickname, error) {
+	return getPaginated[CourseNickname](c, "/api/v1/users/self/course_nicknames", nil)
+}
+
 func (c *CanvasClient) Assignments(courseID int) ([]Assignment, error) {
 	params := url.Values{"per_page": []string{"100"}}
 	return getPaginated[Assignment](c, fmt.Sprintf("/api/v1/courses/%d/assignments", courseID), params)
@@ -106,37 +241,81 @@ func (c *CanvasClient) Submissions(courseID, studentID int) ([]Submission, error
 	return getPaginated[Submission](c, fmt.Sprintf("/api/v1/courses/%d/students/submissions", courseID), params)
 }
 
+// MissingSubmissions returns the assignments Canvas itself flags as missing for
+// the student, across all of their courses.
+func (c *CanvasClient) MissingSubmissions(studentID int) ([]Assignment, error) {
+	params := url.Values{
+		"include[]": []string{"planner_overrides"},
+		"filter[]":  []string{"submittable"},
+		"per_page":  []string{"100"},
+	}
+	return getPaginated[Assignment](c, fmt.Sprintf("/api/v1/users/%d/missing_submissions", studentID), params)
+}
+
+// UndatedAssignments returns the course's assignments that have no due date,
+// with the student's submission included.
+func (c *CanvasClient) UndatedAssignments(studentID, courseID int) ([]Assignment, error) {
+	params := url.Values{
+		"bucket":    []string{"undated"},
+		"include[]": []string{"submission"},
+		"per_page":  []string{"100"},
+	}
+	return getPaginated[Assignment](c, fmt.Sprintf("/api/v1/users/%d/courses/%d/assignments", studentID, courseID), params)
+}
+
+// PlannerItems returns the student's planner to-do items (assignments, quizzes,
+// discussions, pages with to-do dates, planner notes) between start and end.
+func (c *CanvasClient) PlannerItems(studentID int, start, end time.Time) ([]PlannerItem, error) {
+	params := url.Values{
+		"user_id":    []string{fmt.Sprintf("%d", studentID)},
+		"start_date": []string{start.Format(time.RFC3339)},
+		"end_date":   []string{end.Format(time.RFC3339)},
+		"per_page":   []string{"100"},
+	}
+	return getPaginated[PlannerItem](c, "/api/v1/planner/items", params)
+}
+
 type gradingPeriodsResponse struct {
 	GradingPeriods []GradingPeriod `json:"grading_periods"`
 }
 
 func (c *CanvasClient) GradingPeriods(courseID int) ([]GradingPeriod, error) {
-	fullURL := fmt.Sprintf("%s/api/v1/courses/%d/grading_periods", c.baseURL, courseID)
-
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
+	var result gradingPeriodsResponse
+	if err := c.getJSON(fmt.Sprintf("/api/v1/courses/%d/grading_periods", courseID), nil, &result); err != nil {
 		return nil, err
 	}
+	return result.GradingPeriods, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+// LatePolicy returns the course's late policy.
+func (c *CanvasClient) LatePolicy(courseID int) (*LatePolicy, error) {
+	var result latePolicyResponse
+	if err := c.getJSON(fmt.Sprintf("/api/v1/courses/%d/late_policy", courseID), nil, &result); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return &result.LatePolicy, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Canvas API error: %d - %s", resp.StatusCode, string(body))
+// AssignmentDetail returns one assignment with its description and rubric.
+func (c *CanvasClient) AssignmentDetail(courseID, assignmentID int) (*AssignmentDetail, error) {
+	var result AssignmentDetail
+	if err := c.getJSON(fmt.Sprintf("/api/v1/courses/%d/assignments/%d", courseID, assignmentID), nil, &result); err != nil {
+		return nil, err
 	}
+	return &result, nil
+}
 
-	var result gradingPeriodsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+// SubmissionDetail returns the student's submission for an assignment with
+// its earlier attempts, comments and rubric assessment.
+func (c *CanvasClient) SubmissionDetail(courseID, assignmentID, studentID int) (*SubmissionDetail, error) {
+	params := url.Values{
+		"include[]": []string{"submission_history", "submission_comments", "rubric_assessment"},
+	}
+	var result SubmissionDetail
+	if err := c.getJSON(fmt.Sprintf("/api/v1/courses/%d/assignments/%d/submissions/%d", courseID, assignmentID, studentID), params, &result); err != nil {
 		return nil, err
 	}
-
-	return result.GradingPeriods, nil
+	return &result, nil
 }
 
 func (c *CanvasClient) Enrollments(courseID, studentID int, gradingPeriodID string) ([]Enrollment, error) {
@@ -158,6 +337,28 @@ func (c *CanvasClient) AssignmentGroups(courseID int) ([]AssignmentGroup, error)
 	return getPaginated[AssignmentGroup](c, fmt.Sprintf("/api/v1/courses/%d/assignment_groups", courseID), params)
 }
 
+// getJSON fetches a single (unpaginated) object into v.
+func (c *CanvasClient) getJSON(path string, params url.Values, v any) error {
+	fullURL := c.baseURL + path
+	if params != nil {
+		fullURL += "?" + params.Encode()
+	}
+
+	resp, err := c.get(fullURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return newDecodeError(resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
 func getPaginated[T any](c *CanvasClient, path string, params url.Values) ([]T, error) {
 	var result []T
 
@@ -166,23 +367,16 @@ func getPaginated[T any](c *CanvasClient, path string, params url.Values) ([]T,
 		fullURL += "?" + params.Encode()
 	}
 
-	for fullURL != "" {
-		req, err := http.NewRequest("GET", fullURL, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("Authorization", "Bearer "+c.accessToken)
-
-		resp, err := c.httpClient.Do(req)
+	for page := 1; fullURL != ""; page++ {
+		resp, err := c.getPage(fullURL, page)
 		if err != nil {
 			return nil, err
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
+			err := newStatusError(resp)
 			resp.Body.Close()
-			return nil, fmt.Errorf("Canvas API error: %d - %s", resp.StatusCode, string(body))
+			return nil, err
 		}
 
 		body, err := io.ReadAll(resp.Body)
@@ -191,12 +385,12 @@ func getPaginated[T any](c *CanvasClient, path string, params url.Values) ([]T,
 			return nil, err
 		}
 
-		var page []T
-		if err := json.Unmarshal(body, &page); err != nil {
-			return nil, err
+		var items []T
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, newDecodeError(resp.Request.URL.Path, err)
 		}
 
-		result = append(result, page...)
+		result = append(result, items...)
 
 		fullURL = parseNextLink(resp.Header.Get("Link"))
 	}
@@ -204,6 +398,80 @@ func getPaginated[T any](c *CanvasClient, path string, params url.Values) ([]T,
 	return result, nil
 }
 
+// get sends an authenticated GET request. An expired OAuth token is
+// refreshed once and the request retried.
+func (c *CanvasClient) get(fullURL string) (*http.Response, error) {
+	return c.getPage(fullURL, 0)
+}
+
+// getPage is get for one page of a paginated walk; page only labels the
+// request in traces.
+func (c *CanvasClient) getPage(fullURL string, page int) (*http.Response, error) {
+	token := c.token()
+	resp, err := c.send(fullURL, token, page)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.refresh == nil || !tokenRejected(resp) {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if token, err = c.refreshToken(token); err != nil {
+		return nil, err
+	}
+	return c.send(fullURL, token, page)
+}
+
+// tokenRejected reports whether a 401 is about the token itself rather than
+// a lack of access, which Canvas also answers with 401. The body is left
+// readable for the caller.
+func tokenRejected(resp *http.Response) bool {
+	if resp.Header.Get("WWW-Authenticate") != "" {
+		return true
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return err == nil && bytes.Contains(body, []byte("Invalid access token"))
+}
+
+func (c *CanvasClient) send(fullURL, token string, page int) (*http.Response, error) {
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	started := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.tracer.failed(req, page, started, err)
+		return nil, err
+	}
+	c.tracer.wrap(resp, page, started)
+	return resp, nil
+}
+
+func (c *CanvasClient) token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken
+}
+
+// refreshToken replaces a rejected token. Requests running in parallel all
+// fail together, so only the first refreshes; the rest reuse its result.
+func (c *CanvasClient) refreshToken(rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != rejected {
+		return c.accessToken, nil
+	}
+	token, err := c.refresh()
+	if err != nil {
+		return "", err
+	}
+	c.accessToken = token
+	return token, nil
+}
+
 var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
 
 func parseNextLink(linkHeader string) string {
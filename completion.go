@@ -0,0 +1,311 @@
+// ABOUTME: Shell completion script generation for canvas-report.
+// ABOUTME: Builds bash, zsh and fish completions from the command table so they never drift from the CLI.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionFlag describes one flag for the completion generators.
+type completionFlag struct {
+	name       string
+	usage      string
+	hasValue   bool
+	values     []string // Fixed choices for the value, if any
+	isPath     bool
+	repeatable bool
+}
+
+func runCompletion(c *cli, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected one shell: %s", strings.Join(completionShells, ", "))
+	}
+
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout)
+	case "zsh":
+		writeZshCompletion(os.Stdout)
+	case "fish":
+		writeFishCompletion(os.Stdout)
+	default:
+		return fmt.Errorf("unsupported shell %q (want %s)", args[0], strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func completionFlags(register func(fs *flag.FlagSet)) []completionFlag {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	register(fs)
+
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		_, usage := flag.UnquoteUsage(f)
+		_, repeatable := f.Value.(*stringList)
+		cf := completionFlag{name: f.Name, usage: usage, hasValue: !isBoolFlag(f), repeatable: repeatable}
+		switch f.Name {
+		case "format":
+			cf.values = outputFormats
+		case "config", "log-file":
+			cf.isPath = true
+		}
+		flags = append(flags, cf)
+	})
+	return flags
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func globalCompletionFlags() []completionFlag {
+	return completionFlags(func(fs *flag.FlagSet) {
+		(&cli{format: "text"}).registerGlobal(fs)
+	})
+}
+
+func commandCompletionFlags(cmd *command) []completionFlag {
+	if cmd.flags == nil {
+		return nil
+	}
+	return completionFlags(func(fs *flag.FlagSet) {
+		cmd.flags(&cli{}, fs)
+	})
+}
+
+// positionalChoices returns the fixed values a command's arguments can take.
+func positionalChoices(cmd *command) []string {
+	switch cmd.name {
+	case "completion":
+		return completionShells
+	case "config":
+		return configSubcommands
+	case "help":
+		return commandNames()
+	}
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return names
+}
+
+func flagWords(flags []completionFlag) string {
+	words := make([]string, len(flags))
+	for i, f := range flags {
+		words[i] = "--" + f.name
+	}
+	return strings.Join(words, " ")
+}
+
+func writeBashCompletion(w io.Writer) {
+	global := globalCompletionFlags()
+
+	fmt.Fprintln(w, "# bash completion for canvas-report")
+	fmt.Fprintln(w, "# Load with: source <(canvas-report completion bash)")
+	fmt.Fprintln(w, "_canvas_report() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    local cmd="" i skip=0`)
+	fmt.Fprintln(w, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `        if ((skip)); then skip=0; continue; fi`)
+	fmt.Fprintln(w, `        case "${COMP_WORDS[i]}" in`)
+	var valueFlags []string
+	for _, f := range global {
+		if f.hasValue {
+			valueFlags = append(valueFlags, "--"+f.name)
+		}
+	}
+	for _, cmd := range commands {
+		for _, f := range commandCompletionFlags(&cmd) {
+			if f.hasValue {
+				valueFlags = append(valueFlags, "--"+f.name)
+			}
+		}
+	}
+	sort.Strings(valueFlags)
+	valueFlags = compactStrings(valueFlags)
+	fmt.Fprintf(w, "            %s) skip=1 ;;\n", strings.Join(valueFlags, "|"))
+	fmt.Fprintln(w, `            -*) ;;`)
+	fmt.Fprintln(w, `            *) [[ -z "$cmd" ]] && cmd="${COMP_WORDS[i]}" ;;`)
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, `    case "$prev" in`)
+	for _, f := range global {
+		switch {
+		case len(f.values) > 0:
+			fmt.Fprintf(w, "        --%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.name, strings.Join(f.values, " "))
+		case f.isPath:
+			fmt.Fprintf(w, "        --%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.name)
+		}
+	}
+	fmt.Fprintf(w, "        %s) return ;;\n", strings.Join(valueFlags, "|"))
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, `    if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintf(w, "        local opts=%q\n", flagWords(global))
+	fmt.Fprintln(w, `        case "$cmd" in`)
+	for _, cmd := range commands {
+		if flags := commandCompletionFlags(&cmd); len(flags) > 0 {
+			fmt.Fprintf(w, "            %s) opts+=\" %s\" ;;\n", cmd.name, flagWords(flags))
+		}
+	}
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `        COMPREPLY=($(compgen -W "$opts" -- "$cur"))`)
+	fmt.Fprintln(w, `        return`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, `    case "$cmd" in`)
+	fmt.Fprintf(w, "        \"\") COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(commandNames(), " "))
+	for _, cmd := range commands {
+		if choices := positionalChoices(&cmd); len(choices) > 0 {
+			fmt.Fprintf(w, "        %s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", cmd.name, strings.Join(choices, " "))
+		}
+	}
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _canvas_report canvas-report")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef canvas-report")
+	fmt.Fprintln(w, "# Load with: canvas-report completion zsh > \"${fpath[1]}/_canvas-report\"")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_canvas_report() {")
+	fmt.Fprintln(w, "  local -a commands global_flags")
+	fmt.Fprintln(w, "  commands=(")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "    %s\n", zshQuote(cmd.name+":"+cmd.summary))
+	}
+	fmt.Fprintln(w, "  )")
+	fmt.Fprintln(w, "  global_flags=(")
+	for _, f := range globalCompletionFlags() {
+		fmt.Fprintf(w, "    %s\n", zshFlagSpec(f))
+	}
+	fmt.Fprintln(w, "  )")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  local curcontext=\"$curcontext\" state line")
+	fmt.Fprintln(w, "  _arguments -C $global_flags '1: :->command' '*:: :->args'")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  case $state in")
+	fmt.Fprintln(w, "    command)")
+	fmt.Fprintln(w, "      _describe -t commands 'canvas-report command' commands")
+	fmt.Fprintln(w, "      ;;")
+	fmt.Fprintln(w, "    args)")
+	fmt.Fprintln(w, "      case $words[1] in")
+	for _, cmd := range commands {
+		flags := commandCompletionFlags(&cmd)
+		choices := positionalChoices(&cmd)
+		if len(flags) == 0 && len(choices) == 0 {
+			continue
+		}
+		specs := []string{"$global_flags"}
+		for _, f := range flags {
+			specs = append(specs, zshFlagSpec(f))
+		}
+		if len(choices) > 0 {
+			specs = append(specs, zshQuote("1:"+cmd.args+":("+strings.Join(choices, " ")+")"))
+		}
+		fmt.Fprintf(w, "        %s) _arguments %s ;;\n", cmd.name, strings.Join(specs, " "))
+	}
+	fmt.Fprintln(w, "        *) _arguments $global_flags ;;")
+	fmt.Fprintln(w, "      esac")
+	fmt.Fprintln(w, "      ;;")
+	fmt.Fprintln(w, "  esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_canvas_report \"$@\"")
+}
+
+func zshFlagSpec(f completionFlag) string {
+	desc := strings.NewReplacer("[", "(", "]", ")", ":", " ").Replace(f.usage)
+	if !f.hasValue {
+		return zshQuote("--" + f.name + "[" + desc + "]")
+	}
+
+	spec := "--" + f.name + "=[" + desc + "]:" + f.name + ":"
+	switch {
+	case len(f.values) > 0:
+		spec += "(" + strings.Join(f.values, " ") + ")"
+	case f.isPath:
+		spec += "_files"
+	}
+	if f.repeatable {
+		spec = "*" + spec
+	}
+	return zshQuote(spec)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for canvas-report")
+	fmt.Fprintln(w, "# Load with: canvas-report completion fish > ~/.config/fish/completions/canvas-report.fish")
+	fmt.Fprintln(w, "complete -c canvas-report -f")
+	fmt.Fprintln(w)
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c canvas-report -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	fmt.Fprintln(w)
+
+	for _, f := range globalCompletionFlags() {
+		fmt.Fprintln(w, fishFlagLine("", f))
+	}
+
+	for _, cmd := range commands {
+		condition := "-n " + fishQuote("__fish_seen_subcommand_from "+cmd.name) + " "
+		for _, f := range commandCompletionFlags(&cmd) {
+			fmt.Fprintln(w, fishFlagLine(condition, f))
+		}
+		if choices := positionalChoices(&cmd); len(choices) > 0 {
+			fmt.Fprintf(w, "complete -c canvas-report %s-a %s\n", condition, fishQuote(strings.Join(choices, " ")))
+		}
+	}
+}
+
+func fishFlagLine(condition string, f completionFlag) string {
+	line := "complete -c canvas-report " + condition + "-l " + f.name
+	switch {
+	case len(f.values) > 0:
+		line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+	case f.isPath:
+		line += " -r -F"
+	case f.hasValue:
+		line += " -x"
+	}
+	return line + " -d " + fishQuote(f.usage)
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// compactStrings removes adjacent duplicates from a sorted slice.
+func compactStrings(s []string) []string {
+	var out []string
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
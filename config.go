@@ -8,17 +8,110 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	defaultProfile = "default"
+	allProfiles    = "all"
+)
+
+// Profile is one Canvas instance and the credentials for it. The OAuth
+// fields are set by the login command; profiles using a personal access
+// token leave them empty.
+type Profile struct {
+	BaseURL      string `yaml:"base_url,omitempty"`
+	AccessToken  string `yaml:"access_token,omitempty"`
+	ClientID     string `yaml:"client_id,omitempty"`
+	ClientSecret string `yaml:"client_secret,omitempty"`
+	RefreshToken string `yaml:"refresh_token,omitempty"`
+}
+
+// Config is the top-level config file. Its inline Profile is the default
+// profile; Profiles holds any additional Canvas instances by name.
 type Config struct {
-	BaseURL     string `yaml:"base_url"`
-	AccessToken string `yaml:"access_token"`
+	Profile     `yaml:",inline"`
+	Profiles    map[string]*Profile      `yaml:"profiles,omitempty"`
+	Students    Filter                   `yaml:"students,omitempty"`
+	Courses     Filter                   `yaml:"courses,omitempty"`
+	PerStudent  map[string]StudentConfig `yaml:"per_student,omitempty"`
+	CourseNames map[string]string        `yaml:"course_names,omitempty"`
+	Notify      NotifyConfig             `yaml:"notify,omitempty"`
+	Email       EmailConfig              `yaml:"email,omitempty"`
+	Report      ReportConfig             `yaml:"report,omitempty"`
+
+	// Where access tokens live: plaintext (in this file), passphrase or helper
+	TokenStorage     string `yaml:"token_storage,omitempty"`
+	CredentialHelper string `yaml:"credential_helper,omitempty"`
+
+	secrets secretStore
+	stored  map[string]string // Secret values as last read from or written to the store
+}
+
+// StudentConfig holds settings that apply to a single observee, keyed in
+// Config.PerStudent by a name pattern.
+type StudentConfig struct {
+	Courses Filter `yaml:"courses,omitempty"`
+}
+
+// Filters returns the student and course filters configured in the file.
+func (c *Config) Filters() Filters {
+	f := Filters{Students: c.Students, Courses: c.Courses}
+	if len(c.PerStudent) > 0 {
+		f.PerStudent = make(map[string]Filter, len(c.PerStudent))
+		for name, sc := range c.PerStudent {
+			f.PerStudent[name] = sc.Courses
+		}
+	}
+	return f
+}
+
+// ProfileNames lists the configured profiles, the default profile first.
+func (c *Config) ProfileNames() []string {
+	var names []string
+	if c.Profile != (Profile{}) || len(c.Profiles) == 0 {
+		names = append(names, defaultProfile)
+	}
+	var named []string
+	for name := range c.Profiles {
+		named = append(named, name)
+	}
+	sort.Strings(named)
+	return append(names, named...)
+}
+
+// GetProfile returns the named profile. An empty name or "default" is the
+// top-level profile.
+func (c *Config) GetProfile(name string) (*Profile, error) {
+	if name == "" || name == defaultProfile {
+		return &c.Profile, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok || p == nil {
+		return nil, fmt.Errorf("no profile named %q (have %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+// SelectProfiles resolves a --profile value to profile names; "all" selects
+// every configured profile.
+func (c *Config) SelectProfiles(name string) ([]string, error) {
+	if name == allProfiles {
+		return c.ProfileNames(), nil
+	}
+	if _, err := c.GetProfile(name); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = defaultProfile
+	}
+	return []string{name}, nil
 }
 
-func configPath() (string, error) {
+func defaultConfigPath() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -26,12 +119,35 @@ func configPath() (string, error) {
 	return filepath.Join(home, ".config", "canvas-report", "config.yaml"), nil
 }
 
-func loadConfig() (*Config, error) {
-	path, err := configPath()
+// loadConfig reads the config file and fetches its tokens from the secret
+// store. Tokens still in plaintext under another storage mode are moved
+// into the store.
+func loadConfig(path string) (*Config, error) {
+	cfg, err := readConfig(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.secrets, err = newSecretStore(cfg, path); err != nil {
+		return nil, err
+	}
+	migrated, err := cfg.loadSecrets()
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		if err := saveConfig(path, cfg); err != nil {
+			return nil, fmt.Errorf("moving tokens out of %s: %w", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "Moved access tokens from %s to %s storage.\n", path, cfg.TokenStorage)
+	}
+
+	return cfg, nil
+}
+
+// readConfig parses the config file as written, without touching the secret
+// store.
+func readConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -45,17 +161,17 @@ func loadConfig() (*Config, error) {
 	return &cfg, nil
 }
 
-func saveConfig(cfg *Config) error {
-	path, err := configPath()
-	if err != nil {
+func saveConfig(path string, cfg *Config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
 		return err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+	onDisk, err := cfg.storeSecrets()
+	if err != nil {
 		return err
 	}
 
-	data, err := yaml.Marshal(cfg)
+	data, err := yaml.Marshal(onDisk)
 	if err != nil {
 		return err
 	}
@@ -63,7 +179,7 @@ func saveConfig(cfg *Config) error {
 	return os.WriteFile(path, data, 0600)
 }
 
-func runSetup() (*Config, error) {
+func runSetup(path string) (*Config, error) {
 	fmt.Println("No configuration found. Let's set it up.")
 	fmt.Println()
 
@@ -77,17 +193,20 @@ func runSetup() (*Config, error) {
 	baseURL = strings.TrimSpace(baseURL)
 	baseURL = strings.TrimSuffix(baseURL, "/")
 
-	fmt.Print("API Token (from Canvas > Settings > New Access Token): ")
+	fmt.Print("API Token (from Canvas > Settings > New Access Token, or leave blank to use OAuth): ")
 	token, err := reader.ReadString('\n')
 	if err != nil {
 		return nil, err
 	}
 	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, fmt.Errorf("no token entered; if your school doesn't let parents create tokens, run 'canvas-report login' to sign in with OAuth")
+	}
 
-	cfg := &Config{
+	cfg := &Config{Profile: Profile{
 		BaseURL:     baseURL,
 		AccessToken: token,
-	}
+	}}
 
 	fmt.Println()
 	fmt.Print("Testing connection... ")
@@ -111,14 +230,23 @@ func runSetup() (*Config, error) {
 	}
 	fmt.Printf("found %d student(s): %s\n", len(observees), strings.Join(names, ", "))
 
-	if err := saveConfig(cfg); err != nil {
-		return nil, fmt.Errorf("could not save config: %w", err)
+	fmt.Println()
+	fmt.Print("Encrypt the token with a passphrase instead of storing it in plain text? [y/N]: ")
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y") {
+		cfg.TokenStorage = storagePassphrase
+		if cfg.secrets, err = newSecretStore(cfg, path); err != nil {
+			return nil, err
+		}
 	}
 
-	path, err := configPath()
-	if err != nil {
-		return nil, fmt.Errorf("could not determine config path: %w", err)
+	if err := saveConfig(path, cfg); err != nil {
+		return nil, fmt.Errorf("could not save config: %w", err)
 	}
+
 	fmt.Printf("\nConfiguration saved to %s\n\n", path)
 
 	return cfg, nil
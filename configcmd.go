@@ -0,0 +1,317 @@
+// ABOUTME: The config subcommands for canvas-report.
+// ABOUTME: Shows, sets, tests and edits the config file and its named profiles.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+var configSubcommands = []string{"show", "set", "test", "edit"}
+
+var configKeys = []string{"base_url", "access_token", "client_id", "client_secret", "token_storage", "credential_helper"}
+
+func runConfig(c *cli, args []string) error {
+	sub := "show"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+
+	// Allow global flags after the subcommand too ("config set --profile x ...")
+	fs := c.commandFlags(findCommand("config"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	switch sub {
+	case "show":
+		return runConfigShow(c, args)
+	case "set":
+		return runConfigSet(c, args)
+	case "test":
+		return runConfigTest(c, args)
+	case "edit":
+		return runConfigEdit(c, args)
+	default:
+		return fmt.Errorf("unknown config command %q (want %s)", sub, strings.Join(configSubcommands, ", "))
+	}
+}
+
+func runConfigShow(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	path, err := c.resolvedConfigPath()
+	if err != nil {
+		return err
+	}
+	// Read the file as written so showing it never needs the passphrase
+	cfg, err := readConfig(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no config at %s (run canvas-report to set one up)", path)
+		}
+		return err
+	}
+
+	shown := *cfg
+	shown.Profile = maskProfile(shown.Profile)
+	if len(cfg.Profiles) > 0 {
+		shown.Profiles = make(map[string]*Profile, len(cfg.Profiles))
+		for name, p := range cfg.Profiles {
+			masked := maskProfile(*p)
+			shown.Profiles[name] = &masked
+		}
+	}
+	shown.Email.SMTP.Password = maskToken(cfg.Email.SMTP.Password)
+	if len(cfg.Notify.Webhooks) > 0 {
+		shown.Notify.Webhooks = make([]Webhook, len(cfg.Notify.Webhooks))
+		for i, w := range cfg.Notify.Webhooks {
+			shown.Notify.Webhooks[i] = maskWebhook(w)
+		}
+	}
+
+	data, err := yaml.Marshal(&shown)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("# %s\n", path)
+	fmt.Print(string(data))
+	return nil
+}
+
+// maskProfile hides a profile's secrets.
+func maskProfile(p Profile) Profile {
+	p.AccessToken = maskToken(p.AccessToken)
+	p.RefreshToken = maskToken(p.RefreshToken)
+	p.ClientSecret = maskToken(p.ClientSecret)
+	return p
+}
+
+// maskWebhook hides a webhook's header values, which usually carry tokens.
+func maskWebhook(w Webhook) Webhook {
+	if len(w.Headers) > 0 {
+		headers := make(map[string]string, len(w.Headers))
+		for name, value := range w.Headers {
+			headers[name] = maskToken(value)
+		}
+		w.Headers = headers
+	}
+	return w
+}
+
+// maskToken hides all but the last four characters of a secret.
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return strings.Repeat("*", len(token))
+	}
+	return strings.Repeat("*", 8) + token[len(token)-4:]
+}
+
+func runConfigSet(c *cli, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: canvas-report config set [--profile NAME] KEY [VALUE] (keys: %s)", strings.Join(configKeys, ", "))
+	}
+	key := args[0]
+
+	path, err := c.resolvedConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if os.IsNotExist(err) {
+		cfg, err = &Config{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	name := c.profile
+	if name == "" {
+		name = defaultProfile
+	}
+	if name == allProfiles {
+		return fmt.Errorf("choose a single profile to change")
+	}
+	p, err := cfg.GetProfile(name)
+	if err != nil {
+		// Setting a key on an unknown profile creates it
+		p = &Profile{}
+		if cfg.Profiles == nil {
+			cfg.Profiles = make(map[string]*Profile)
+		}
+		cfg.Profiles[name] = p
+	}
+
+	var value string
+	if len(args) == 2 {
+		value = args[1]
+	} else {
+		value, err = promptValue(key)
+		if err != nil {
+			return err
+		}
+	}
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "base_url":
+		p.BaseURL = strings.TrimSuffix(value, "/")
+	case "access_token":
+		p.AccessToken = value
+	case "client_id":
+		p.ClientID = value
+	case "client_secret":
+		p.ClientSecret = value
+	case "token_storage", "credential_helper":
+		if key == "token_storage" {
+			cfg.TokenStorage = value
+		} else {
+			cfg.CredentialHelper = value
+		}
+		// Switching stores writes every token to the new one
+		if cfg.secrets, err = newSecretStore(cfg, path); err != nil {
+			return err
+		}
+		cfg.stored = nil
+	default:
+		return fmt.Errorf("unknown key %q (want %s)", key, strings.Join(configKeys, ", "))
+	}
+
+	if err := saveConfig(path, cfg); err != nil {
+		return err
+	}
+	if key == "token_storage" || key == "credential_helper" {
+		fmt.Printf("Set %s in %s\n", key, path)
+	} else {
+		fmt.Printf("Set %s for profile %q in %s\n", key, name, path)
+	}
+	return nil
+}
+
+// secretKeys are the config keys whose values are typed without echo.
+var secretKeys = []string{"access_token", "client_secret", "refresh_token"}
+
+// promptValue reads a value from stdin. Secrets typed at a terminal aren't
+// echoed, so tokens don't end up on screen or in shell history.
+func promptValue(key string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Printf("%s: ", key)
+		if containsString(secretKeys, key) {
+			data, err := term.ReadPassword(fd)
+			fmt.Println()
+			return string(data), err
+		}
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return line, nil
+}
+
+func runConfigTest(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile := c.profile
+	if profile == "" {
+		profile = allProfiles
+	}
+	names, err := cfg.SelectProfiles(profile)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range names {
+		p, _ := cfg.GetProfile(name)
+		fmt.Printf("%s (%s): ", name, p.BaseURL)
+
+		client, err := c.newClient(cfg, name)
+		if err != nil {
+			fmt.Println("missing base_url or access_token")
+			failed++
+			continue
+		}
+
+		observees, err := client.Observees()
+		if err != nil {
+			fmt.Printf("failed: %v\n", err)
+			failed++
+			continue
+		}
+
+		students := make([]string, len(observees))
+		for i, o := range observees {
+			students[i] = studentName(o)
+		}
+		fmt.Printf("ok, %d student(s): %s\n", len(observees), strings.Join(students, ", "))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profile(s) failed", failed, len(names))
+	}
+	return nil
+}
+
+func runConfigEdit(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	path, err := c.resolvedConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := saveConfig(path, &Config{}); err != nil {
+			return err
+		}
+	}
+
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		}
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", editor[0], err)
+	}
+
+	// Catch mistakes now rather than on the next report run
+	cfg, err := readConfig(path)
+	if err == nil {
+		_, err = newSecretStore(cfg, path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s is no longer valid: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+// ABOUTME: The doctor command for canvas-report.
+// ABOUTME: Checks the URL, token, observer links, clock and per-course API access, and prints a pass/fail checklist.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Check outcomes, worst last.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+const maxClockSkew = 5 * time.Minute
+
+// checkResult is one line of the doctor's checklist.
+type checkResult struct {
+	Profile string `json:"profile"`
+	Student string `json:"student,omitempty"`
+	Course  string `json:"course,omitempty"`
+	Name    string `json:"check"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// probeResult is the raw outcome of one request, kept so checks can explain
+// a failure by its status code.
+type probeResult struct {
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+func (p probeResult) ok() bool {
+	return p.err == nil && p.status == http.StatusOK
+}
+
+// problem describes a failed probe in a few words.
+func (p probeResult) problem() string {
+	if p.err != nil {
+		return p.err.Error()
+	}
+	return fmt.Sprintf("%d %s", p.status, strings.ToLower(http.StatusText(p.status)))
+}
+
+// hint suggests what to do about a failed probe.
+func (p probeResult) hint() string {
+	switch {
+	case p.err != nil:
+		return "Canvas couldn't be reached; check the URL and your network connection."
+	case p.status == http.StatusUnauthorized:
+		return "The token is invalid or expired; set a new one with 'canvas-report config set access_token' or run 'canvas-report login'."
+	case p.status == http.StatusForbidden:
+		return "This account isn't allowed to see it; the course may be concluded, or the school limits what observers can see."
+	case p.status == http.StatusNotFound:
+		return "Canvas doesn't have it; the course may have been deleted, or the school has turned this feature off."
+	case p.status == http.StatusTooManyRequests:
+		return "Canvas is rate limiting requests; wait a minute and try again."
+	case p.status >= 500:
+		return "Canvas is having trouble; try again later."
+	}
+	return ""
+}
+
+func probe(client *CanvasClient, path string, params url.Values) probeResult {
+	fullURL := client.baseURL + path
+	if params != nil {
+		fullURL += "?" + params.Encode()
+	}
+	resp, err := client.get(fullURL)
+	if err != nil {
+		return probeResult{err: err}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return probeResult{status: resp.StatusCode, header: resp.Header, body: body, err: err}
+}
+
+func runDoctor(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile := c.profile
+	if profile == "" {
+		profile = allProfiles
+	}
+	names, err := cfg.SelectProfiles(profile)
+	if err != nil {
+		return err
+	}
+
+	filters := cfg.Filters()
+	filters.Students = filters.Students.Merge(Filter{Include: c.students, Exclude: c.excludeStudents})
+	filters.Courses = filters.Courses.Merge(Filter{Include: c.courses, Exclude: c.excludeCourses})
+
+	var results []checkResult
+	for _, name := range names {
+		results = append(results, c.diagnoseProfile(cfg, name, filters)...)
+	}
+
+	if c.format == "json" {
+		if err := writeJSON(os.Stdout, results); err != nil {
+			return err
+		}
+	} else {
+		printChecklist(results)
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.Status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// diagnoseProfile runs every check for one profile. Later checks are skipped
+// once an earlier one shows they can't succeed.
+func (c *cli) diagnoseProfile(cfg *Config, name string, filters Filters) []checkResult {
+	var results []checkResult
+	add := func(res checkResult) checkResult {
+		res.Profile = name
+		results = append(results, res)
+		return res
+	}
+
+	p, _ := cfg.GetProfile(name)
+	if res := add(checkURL(p.BaseURL)); res.Status == checkFail {
+		return results
+	}
+
+	client, err := c.newClient(cfg, name)
+	if err != nil {
+		add(checkResult{Name: "Token", Status: checkFail, Detail: "no access token configured",
+			Hint: "Set one with 'canvas-report config set access_token' or run 'canvas-report login'."})
+		return results
+	}
+
+	self := probe(client, "/api/v1/users/self", nil)
+	if self.err != nil {
+		add(checkResult{Name: "Canvas reachable", Status: checkFail, Detail: self.problem(), Hint: self.hint()})
+		return results
+	}
+	add(checkClock(self.header))
+	if res := add(checkToken(self)); res.Status == checkFail {
+		return results
+	}
+	add(checkTokenExpiry(client, p))
+
+	observees, err := client.Observees()
+	switch {
+	case err != nil:
+		add(checkResult{Name: "Observer links", Status: checkFail, Detail: err.Error(),
+			Hint: "Make sure you're using the parent's observer account, not the student's."})
+		return results
+	case len(observees) == 0:
+		add(checkResult{Name: "Observer links", Status: checkFail, Detail: "no observed students",
+			Hint: "Link the student to this account in Canvas (Settings > Observing > Student pairing code)."})
+		return results
+	}
+	students := make([]string, len(observees))
+	for i, o := range observees {
+		students[i] = studentName(o)
+	}
+	add(checkResult{Name: "Observer links", Status: checkPass, Detail: fmt.Sprintf("%d student(s): %s", len(observees), strings.Join(students, ", "))})
+
+	names := newCourseNamer(client, cfg.CourseNames)
+	for _, student := range filters.filterStudents(observees) {
+		sname := studentName(student)
+		for _, res := range diagnoseStudent(client, student, filters, names) {
+			res.Student = sname
+			add(res)
+		}
+	}
+	if names.err != nil {
+		add(checkResult{Name: "Course nicknames", Status: checkWarn, Detail: names.err.Error(),
+			Hint: "Courses are shown under their Canvas names instead."})
+	}
+	return results
+}
+
+func checkURL(baseURL string) checkResult {
+	res := checkResult{Name: "Canvas URL"}
+	u, err := url.Parse(baseURL)
+	switch {
+	case baseURL == "":
+		res.Status, res.Detail = checkFail, "no base_url configured"
+		res.Hint = "Set it with 'canvas-report config set base_url https://yourschool.instructure.com'."
+	case err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http"):
+		res.Status, res.Detail = checkFail, fmt.Sprintf("%q isn't a valid URL", baseURL)
+		res.Hint = "Use the address you sign in to Canvas at, e.g. https://yourschool.instructure.com."
+	case u.Path != "":
+		res.Status, res.Detail = checkWarn, baseURL+" has a path"
+		res.Hint = "base_url should be just the Canvas address; remove " + u.Path + "."
+	case u.Scheme == "http":
+		res.Status, res.Detail = checkWarn, baseURL+" isn't HTTPS"
+		res.Hint = "Your token is sent unencrypted; use https:// instead."
+	default:
+		res.Status, res.Detail = checkPass, baseURL
+	}
+	return res
+}
+
+// checkClock compares the local clock with Canvas's, since dates like "due
+// tomorrow" and token expiry are only right when they agree.
+func checkClock(header http.Header) checkResult {
+	res := checkResult{Name: "Clock"}
+	server, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		res.Status, res.Detail = checkWarn, "Canvas didn't send its time"
+		return res
+	}
+
+	skew := time.Since(server).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		res.Status, res.Detail = checkWarn, fmt.Sprintf("%s off from Canvas", skew)
+		res.Hint = "Turn on automatic time sync; due-date sections may be off by a day near midnight."
+		return res
+	}
+	res.Status, res.Detail = checkPass, fmt.Sprintf("within %s of Canvas", max(skew, time.Second))
+	return res
+}
+
+func checkToken(self probeResult) checkResult {
+	res := checkResult{Name: "Token"}
+	if !self.ok() {
+		res.Status, res.Detail, res.Hint = checkFail, self.problem(), self.hint()
+		return res
+	}
+
+	var user struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(self.body, &user); err != nil {
+		res.Status, res.Detail = checkFail, "unexpected reply from Canvas: "+err.Error()
+		res.Hint = "Check that base_url points at Canvas and not a login portal."
+		return res
+	}
+	res.Status, res.Detail = checkPass, "valid, signed in as "+user.Name
+	return res
+}
+
+// checkTokenExpiry looks up the token's expiry date. Canvas identifies a
+// token by its first five characters, its "token hint".
+func checkTokenExpiry(client *CanvasClient, p *Profile) checkResult {
+	res := checkResult{Name: "Token expiry"}
+	if p.RefreshToken != "" {
+		res.Status, res.Detail = checkPass, "OAuth login; access tokens are refreshed automatically"
+		return res
+	}
+
+	token := client.token()
+	hint := token[:min(5, len(token))]
+	info := probe(client, "/api/v1/users/self/tokens/"+url.PathEscape(hint), nil)
+	if !info.ok() {
+		res.Status, res.Detail = checkWarn, "couldn't read token details ("+info.problem()+")"
+		return res
+	}
+
+	var details struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(info.body, &details); err != nil || details.ExpiresAt == nil {
+		res.Status, res.Detail = checkPass, "no expiry date"
+		return res
+	}
+
+	left := time.Until(*details.ExpiresAt)
+	res.Detail = "expires " + details.ExpiresAt.Local().Format("Mon Jan 2, 2006")
+	if left < 14*24*time.Hour {
+		res.Status = checkWarn
+		res.Hint = "Create a new token in Canvas soon and set it with 'canvas-report config set access_token'."
+	} else {
+		res.Status = checkPass
+	}
+	return res
+}
+
+// courseEndpoint is one per-course API the report depends on.
+type courseEndpoint struct {
+	name   string
+	path   string
+	params func(studentID int) url.Values
+	// For endpoints the report can do without: what a 404 means, and what
+	// the report loses when it fails otherwise, which only warns
+	absent, without string
+}
+
+var courseEndpoints = []courseEndpoint{
+	{name: "assignments", path: "/api/v1/courses/%d/assignments"},
+	{name: "submissions", path: "/api/v1/courses/%d/students/submissions", params: func(id int) url.Values {
+		return url.Values{"student_ids[]": {fmt.Sprint(id)}}
+	}},
+	{name: "assignment groups", path: "/api/v1/courses/%d/assignment_groups"},
+	{name: "enrollment", path: "/api/v1/courses/%d/enrollments", params: func(id int) url.Values {
+		return url.Values{"user_id": {fmt.Sprint(id)}, "type[]": {"StudentEnrollment"}}
+	}},
+	{name: "late policy", path: "/api/v1/courses/%d/late_policy", absent: "no late policy", without: "Impacts will ignore late penalties."},
+}
+
+func diagnoseStudent(client *CanvasClient, student Observee, filters Filters, names *courseNamer) []checkResult {
+	var results []checkResult
+
+	courses, err := client.Courses(student.ID)
+	if err != nil {
+		return append(results, checkResult{Name: "Courses", Status: checkFail, Detail: err.Error(),
+			Hint: "The observer link may be pending; check Settings > Observing in Canvas."})
+	}
+	courses = filters.filterCourses(student, courses)
+	results = append(results, checkResult{Name: "Courses", Status: checkPass, Detail: fmt.Sprintf("%d active", len(courses))})
+
+	missing := probe(client, fmt.Sprintf("/api/v1/users/%d/missing_submissions", student.ID), url.Values{"per_page": {"1"}})
+	if missing.ok() {
+		results = append(results, checkResult{Name: "Missing flags", Status: checkPass, Detail: "Canvas missing flags readable"})
+	} else {
+		results = append(results, checkResult{Name: "Missing flags", Status: checkWarn, Detail: missing.problem(),
+			Hint: "Missing work falls back to guessing from due dates; those rows are listed as Missing (✗) rather than Unconfirmed (?), which needs Canvas's flags."})
+	}
+
+	for _, course := range courses {
+		results = append(results, diagnoseCourse(client, course, student.ID, names.name(course)))
+	}
+	return results
+}
+
+// diagnoseCourse probes each endpoint the report uses for one course and
+// folds the outcome into a single checklist line.
+func diagnoseCourse(client *CanvasClient, course Course, studentID int, name string) checkResult {
+	res := checkResult{Course: name, Name: name, Status: checkPass}
+	var passed, problems, hints []string
+
+	for _, ep := range courseEndpoints {
+		params := url.Values{"per_page": {"1"}}
+		if ep.params != nil {
+			for k, v := range ep.params(studentID) {
+				params[k] = v
+			}
+		}
+		pr := probe(client, fmt.Sprintf(ep.path, course.ID), params)
+		switch {
+		case pr.ok():
+			passed = append(passed, ep.name)
+			continue
+		case ep.absent != "" && pr.err == nil && pr.status == http.StatusNotFound:
+			passed = append(passed, ep.absent)
+			continue
+		}
+		problems = append(problems, ep.name+": "+pr.problem())
+		h := pr.hint()
+		if ep.without != "" {
+			if res.Status == checkPass {
+				res.Status = checkWarn
+			}
+			h = ep.without
+		} else {
+			res.Status = checkFail
+		}
+		if h != "" && !containsString(hints, h) {
+			hints = append(hints, h)
+		}
+	}
+
+	periods, err := client.GradingPeriods(course.ID)
+	switch {
+	case err != nil:
+		if res.Status == checkPass {
+			res.Status = checkWarn
+		}
+		problems = append(problems, "grading periods hidden")
+		hints = append(hints, "Grades will cover the whole course instead of the current grading period.")
+	case len(periods) == 0:
+		passed = append(passed, "no grading periods")
+	case currentGradingPeriod(periods) == nil:
+		passed = append(passed, fmt.Sprintf("%d grading period(s)", len(periods)))
+		problems = append(problems, "none current")
+		if res.Status == checkPass {
+			res.Status = checkWarn
+		}
+		hints = append(hints, "No grading period covers today, so the course may be between terms or concluded.")
+	default:
+		passed = append(passed, fmt.Sprintf("%d grading period(s)", len(periods)))
+	}
+
+	res.Detail = strings.Join(append(problems, passed...), ", ")
+	res.Hint = strings.Join(hints, " ")
+	return res
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func printChecklist(results []checkResult) {
+	green := color.New(color.FgGreen, color.Bold)
+	yellow := color.New(color.FgYellow, color.Bold)
+	red := color.New(color.FgRed, color.Bold)
+	dim := color.New(color.Faint)
+	bold := color.New(color.Bold)
+
+	// Student rows are indented further, so measure names with their indent
+	width := 0
+	for _, res := range results {
+		n := len(res.Name)
+		if res.Student != "" {
+			n += 2
+		}
+		width = max(width, n)
+	}
+	width = min(width, 34)
+
+	lastProfile, lastStudent := "", ""
+	for i, res := range results {
+		if res.Profile != lastProfile {
+			if i > 0 {
+				fmt.Println()
+			}
+			bold.Printf("Profile %s\n", res.Profile)
+			lastProfile, lastStudent = res.Profile, ""
+		}
+		if res.Student != lastStudent {
+			fmt.Println()
+			bold.Printf("  %s\n", res.Student)
+			lastStudent = res.Student
+		}
+
+		indent, w := "  ", width
+		if res.Student != "" {
+			indent, w = "    ", width-2
+		}
+		switch res.Status {
+		case checkPass:
+			fmt.Print(indent + green.Sprint("✓") + " ")
+		case checkWarn:
+			fmt.Print(indent + yellow.Sprint("!") + " ")
+		default:
+			fmt.Print(indent + red.Sprint("✗") + " ")
+		}
+		fmt.Printf("%-*s  %s\n", w, truncateString(res.Name, w), res.Detail)
+		if res.Hint != "" && res.Status != checkPass {
+			dim.Printf("%s    %s\n", indent, res.Hint)
+		}
+	}
+	fmt.Println()
+	printCheckSummary(results)
+}
+
+func printCheckSummary(results []checkResult) {
+	counts := map[string]int{}
+	for _, res := range results {
+		counts[res.Status]++
+	}
+	summary := fmt.Sprintf("%d passed, %d warning(s), %d failed", counts[checkPass], counts[checkWarn], counts[checkFail])
+	switch {
+	case counts[checkFail] > 0:
+		color.New(color.FgRed).Println(summary)
+	case counts[checkWarn] > 0:
+		color.New(color.FgYellow).Println(summary)
+	default:
+		color.New(color.FgGreen).Println(summary)
+	}
+}
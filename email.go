@@ -0,0 +1,331 @@
+// ABOUTME: Email delivery of the report for canvas-report.
+// ABOUTME: Builds multipart HTML/plain-text messages per recipient and sends them over SMTP with STARTTLS or TLS.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const envSMTPPassword = "CANVAS_REPORT_SMTP_PASSWORD"
+
+// SMTP connection security modes.
+var smtpTLSModes = []string{"starttls", "tls", "none"}
+
+// EmailConfig is the email section of the config file.
+type EmailConfig struct {
+	SMTP       SMTPConfig       `yaml:"smtp,omitempty"`
+	From       string           `yaml:"from,omitempty"`
+	Subject    string           `yaml:"subject,omitempty"` // Default "Canvas report"; names and date are appended
+	Recipients []EmailRecipient `yaml:"recipients,omitempty"`
+}
+
+// SMTPConfig is the outgoing mail server.
+type SMTPConfig struct {
+	Host     string `yaml:"host,omitempty"`
+	Port     int    `yaml:"port,omitempty"` // Default 587, or 465 with tls
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	TLS      string `yaml:"tls,omitempty"` // starttls (default), tls or none
+}
+
+// EmailRecipient is one address and the students whose reports it gets.
+type EmailRecipient struct {
+	Address  string `yaml:"address"`
+	Students Filter `yaml:"students,omitempty"` // Empty means every student
+}
+
+func emailFlags(c *cli, fs *flag.FlagSet) {
+	fs.BoolVar(&c.dryRun, "dry-run", false, "print the messages instead of sending them")
+	fs.StringVar(&c.emailTo, "to", "", "only email this `address` (every student unless it's a configured recipient)")
+}
+
+func runEmail(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+	ec := cfg.Email
+	if v := os.Getenv(envSMTPPassword); v != "" {
+		ec.SMTP.Password = v
+	}
+	recipients, err := ec.recipients(c.emailTo)
+	if err != nil {
+		return err
+	}
+	if err := ec.validate(c.dryRun); err != nil {
+		return err
+	}
+
+	reports, err := c.newReports(cfg, nil)
+	if err != nil {
+		return err
+	}
+	var students []studentData
+	for _, r := range reports {
+		r.quiet = true
+		collected, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		students = append(students, collected...)
+	}
+
+	failed := 0
+	for _, rcpt := range recipients {
+		var theirs []studentData
+		for _, data := range students {
+			if rcpt.Students.Allows(data.id, data.name) {
+				theirs = append(theirs, data)
+			}
+		}
+		if len(theirs) == 0 {
+			fmt.Fprintf(os.Stderr, "%s: no matching students; not sent\n", rcpt.Address)
+			continue
+		}
+
+		msg, err := ec.message(reports[0], rcpt.Address, theirs)
+		if err != nil {
+			return err
+		}
+		if c.dryRun {
+			os.Stdout.Write(msg)
+			fmt.Println()
+			continue
+		}
+		if err := ec.SMTP.send(ec.From, rcpt.Address, msg); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", rcpt.Address, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Sent %s to %s\n", studentNames(theirs), rcpt.Address)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d email(s) could not be sent", failed)
+	}
+	return nil
+}
+
+// recipients returns the configured recipients, or just to when it's set.
+func (e EmailConfig) recipients(to string) ([]EmailRecipient, error) {
+	if to != "" {
+		for _, r := range e.Recipients {
+			if strings.EqualFold(r.Address, to) {
+				return []EmailRecipient{r}, nil
+			}
+		}
+		return []EmailRecipient{{Address: to}}, nil
+	}
+	if len(e.Recipients) == 0 {
+		return nil, errors.New("no email recipients configured; add an email section to the config file or use --to")
+	}
+	return e.Recipients, nil
+}
+
+func (e EmailConfig) validate(dryRun bool) error {
+	if e.From == "" {
+		return errors.New("email.from is not set")
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		return fmt.Errorf("email.from: %w", err)
+	}
+	for _, r := range e.Recipients {
+		if _, err := mail.ParseAddress(r.Address); err != nil {
+			return fmt.Errorf("email recipient %q: %w", r.Address, err)
+		}
+	}
+	if dryRun {
+		return nil
+	}
+	if e.SMTP.Host == "" {
+		return errors.New("email.smtp.host is not set")
+	}
+	if e.SMTP.TLS != "" && !containsString(smtpTLSModes, e.SMTP.TLS) {
+		return fmt.Errorf("email.smtp.tls: unknown mode %q (want %s)", e.SMTP.TLS, strings.Join(smtpTLSModes, ", "))
+	}
+	return nil
+}
+
+func studentNames(students []studentData) string {
+	names := make([]string, len(students))
+	for i, s := range students {
+		names[i] = s.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// message builds a multipart/alternative email with the report as plain
+// text and as HTML tables.
+func (e EmailConfig) message(r *Report, to string, students []studentData) ([]byte, error) {
+	var text bytes.Buffer
+	noColor := color.NoColor
+	color.NoColor = true
+	r.printStudents(&text, students)
+	color.NoColor = noColor
+
+	var html bytes.Buffer
+	if err := renderHTML(&html, students); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	subject := e.Subject
+	if subject == "" {
+		subject = "Canvas report"
+	}
+	subject = fmt.Sprintf("%s for %s - %s", subject, studentNames(students), time.Now().Format("Mon Jan 2"))
+
+	var msg bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&msg, "%s: %s\r\n", name, value) }
+	header("From", e.From)
+	header("To", to)
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(e.From))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+func messageID(from string) string {
+	domain := "canvas-report.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+func (s SMTPConfig) mode() string {
+	if s.TLS == "" {
+		return "starttls"
+	}
+	return s.TLS
+}
+
+func (s SMTPConfig) addr() string {
+	port := s.Port
+	if port == 0 {
+		port = 587
+		if s.mode() == "tls" {
+			port = 465
+		}
+	}
+	return net.JoinHostPort(s.Host, strconv.Itoa(port))
+}
+
+// send delivers one message. Credentials are only sent over TLS, or to a
+// server on localhost.
+func (s SMTPConfig) send(from, to string, msg []byte) error {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return err
+	}
+	toAddr, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", s.addr(), 30*time.Second)
+	if err != nil {
+		return err
+	}
+	tlsConfig := &tls.Config{ServerName: s.Host}
+	if s.mode() == "tls" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.mode() == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s doesn't offer STARTTLS; set email.smtp.tls to tls or none", s.Host)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("signing in to %s: %w", s.Host, err)
+		}
+	}
+
+	if err := client.Mail(fromAddr.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(toAddr.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, bytes.NewReader(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
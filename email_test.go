@@ -0,0 +1,247 @@
+// ABOUTME: Tests for canvas-report email delivery.
+// ABOUTME: Sends a report to an in-process SMTP server and checks the envelope, headers and MIME parts.
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the test server saw from one client.
+type smtpSession struct {
+	auth string // Decoded AUTH PLAIN credentials
+	from string
+	rcpt []string
+	data string
+}
+
+// serveSMTP accepts one connection on l, speaking just enough SMTP for
+// SMTPConfig.send, and reports what it received.
+func serveSMTP(t *testing.T, l net.Listener) <-chan smtpSession {
+	t.Helper()
+	done := make(chan smtpSession, 1)
+	go func() {
+		var s smtpSession
+		defer func() { done <- s }()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				mech, creds, _ := strings.Cut(arg, " ")
+				decoded, _ := base64.StdEncoding.DecodeString(creds)
+				if mech == "PLAIN" {
+					s.auth = string(decoded)
+				}
+				reply("235 ok")
+			case "MAIL":
+				s.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+				reply("250 ok")
+			case "RCPT":
+				s.rcpt = append(s.rcpt, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(l, "."))
+				}
+				s.data = data.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return done
+}
+
+func TestEmailSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	sessions := serveSMTP(t, l)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	ec := EmailConfig{
+		SMTP:    SMTPConfig{Host: "127.0.0.1", Port: port, Username: "me", Password: "pw", TLS: "none"},
+		From:    "Parent <parent@example.com>",
+		Subject: "Weekly report",
+	}
+	points := 20.0
+	students := []studentData{{
+		id:   1,
+		name: "Jane Doe",
+		sections: []reportSection{{
+			kind:  sectionMissing,
+			title: sectionTitles[sectionMissing],
+			assignments: []EnrichedAssignment{{
+				Name:           "Vision Board Organizer",
+				CourseName:     "English",
+				DueAt:          time.Now().AddDate(0, 0, -3),
+				PointsPossible: &points,
+				Status:         "Missing",
+			}},
+		}},
+	}}
+	r := &Report{sections: []string{sectionMissing}, format: "text"}
+
+	to := "Grandma <grandma@example.com>"
+	msg, err := ec.message(r, to, students)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SMTP.send(ec.From, to, msg); err != nil {
+		t.Fatal(err)
+	}
+	s := <-sessions
+
+	if s.auth != "\x00me\x00pw" {
+		t.Errorf("AUTH PLAIN credentials = %q, want \\x00me\\x00pw", s.auth)
+	}
+	if s.from != "parent@example.com" {
+		t.Errorf("MAIL FROM = %q, want parent@example.com", s.from)
+	}
+	if len(s.rcpt) != 1 || s.rcpt[0] != "grandma@example.com" {
+		t.Errorf("RCPT TO = %q, want just grandma@example.com", s.rcpt)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatalf("parsing the message: %v\n%s", err, s.data)
+	}
+	if got := m.Header.Get("To"); got != to {
+		t.Errorf("To = %q, want %q", got, to)
+	}
+	if got := m.Header.Get("From"); got != ec.From {
+		t.Errorf("From = %q, want %q", got, ec.From)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil || !strings.HasPrefix(subject, "Weekly report for Jane Doe - ") {
+		t.Errorf("Subject = %q (%v), want it to start with %q", subject, err, "Weekly report for Jane Doe - ")
+	}
+	for _, name := range []string{"Date", "Message-ID"} {
+		if m.Header.Get(name) == "" {
+			t.Errorf("missing %s header", name)
+		}
+	}
+	if got := m.Header.Get("MIME-Version"); got != "1.0" {
+		t.Errorf("MIME-Version = %q, want 1.0", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", m.Header.Get("Content-Type"), err)
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	// The text tables size to the terminal, so long names may be cut short
+	wantParts := []struct {
+		contentType string
+		mentions    []string
+	}{
+		{"text/plain", []string{"Jane Doe", "MISSING/INCOMPLETE (1)", "1 missing"}},
+		{"text/html", []string{"Jane Doe", "Vision Board Organizer", "English"}},
+	}
+	for i, want := range wantParts {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i+1, err)
+		}
+		if got, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); got != want.contentType {
+			t.Errorf("part %d Content-Type = %q, want %s", i+1, got, want.contentType)
+		}
+		if got := part.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+			t.Errorf("part %d Content-Transfer-Encoding = %q, want quoted-printable", i+1, got)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("part %d: decoding: %v", i+1, err)
+		}
+		for _, text := range want.mentions {
+			if !strings.Contains(string(body), text) {
+				t.Errorf("part %d (%s) doesn't mention %q", i+1, want.contentType, text)
+			}
+		}
+	}
+	if _, err := mr.NextRawPart(); err != io.EOF {
+		t.Errorf("want exactly %d parts, got more (%v)", len(wantParts), err)
+	}
+}
+
+func TestEmailRecipients(t *testing.T) {
+	ec := EmailConfig{Recipients: []EmailRecipient{
+		{Address: "grandma@example.com", Students: Filter{Include: []string{"Jane"}}},
+		{Address: "parent@example.com"},
+	}}
+	tests := []struct {
+		to   string
+		want []string
+	}{
+		{"", []string{"grandma@example.com", "parent@example.com"}},
+		{"Parent@Example.com", []string{"parent@example.com"}},
+		{"someone@example.com", []string{"someone@example.com"}},
+	}
+	for _, tt := range tests {
+		got, err := ec.recipients(tt.to)
+		if err != nil {
+			t.Errorf("recipients(%q): %v", tt.to, err)
+			continue
+		}
+		var addrs []string
+		for _, r := range got {
+			addrs = append(addrs, r.Address)
+		}
+		if strings.Join(addrs, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("recipients(%q) = %v, want %v", tt.to, addrs, tt.want)
+		}
+	}
+
+	if _, err := (EmailConfig{}).recipients(""); err == nil {
+		t.Error("recipients with none configured and no --to should fail")
+	}
+
+	grandma := ec.Recipients[0].Students
+	if !grandma.Allows(1, "Jane Doe") || grandma.Allows(2, "Tommy Doe") {
+		t.Error("grandma's student filter should allow only Jane")
+	}
+}
@@ -0,0 +1,215 @@
+// ABOUTME: Typed Canvas API errors and the data problems collected while building a report.
+// ABOUTME: Lets callers tell auth, permission, missing and rate-limit failures apart instead of discarding them.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Kinds of Canvas API failure, for use with errors.Is.
+var (
+	ErrAuth        = errors.New("not authorized")
+	ErrForbidden   = errors.New("forbidden")
+	ErrNotFound    = errors.New("not found")
+	ErrRateLimited = errors.New("rate limited")
+	ErrDecode      = errors.New("unexpected response")
+)
+
+// APIError is a failed Canvas API request.
+type APIError struct {
+	Kind       error // One of the Err kinds above; nil for other failures such as 500s
+	StatusCode int   // Zero for decode errors
+	Path       string
+	Message    string
+	Err        error // The underlying decode error, if any
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("Canvas API error: %v from %s: %v", e.Kind, e.Path, e.Err)
+	}
+	return fmt.Sprintf("Canvas API error: %d - %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError builds an APIError from a non-200 response, consuming its
+// body.
+func newStatusError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Path:       resp.Request.URL.Path,
+		Message:    canvasErrorMessage(body),
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		e.Kind = ErrAuth
+	case http.StatusForbidden:
+		e.Kind = ErrForbidden
+	case http.StatusNotFound:
+		e.Kind = ErrNotFound
+	case http.StatusTooManyRequests:
+		e.Kind = ErrRateLimited
+	}
+	// Canvas throttles with a 403 and this body rather than a 429
+	if resp.StatusCode == http.StatusForbidden && strings.Contains(e.Message, "Rate Limit Exceeded") {
+		e.Kind = ErrRateLimited
+	}
+	return e
+}
+
+func newDecodeError(path string, err error) *APIError {
+	return &APIError{Kind: ErrDecode, Path: path, Err: err}
+}
+
+// canvasErrorMessage pulls the human-readable message out of a Canvas error
+// body, falling back to the raw body.
+func canvasErrorMessage(body []byte) string {
+	var parsed struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		if len(parsed.Errors) > 0 && parsed.Errors[0].Message != "" {
+			return parsed.Errors[0].Message
+		}
+		if parsed.Message != "" {
+			return parsed.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// stageError records which step of fetching a course failed.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string { return e.stage + ": " + e.err.Error() }
+func (e *stageError) Unwrap() error { return e.err }
+
+func atStage(stage string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &stageError{stage: stage, err: err}
+}
+
+// dataProblem is one piece of a student's data that couldn't be fetched.
+type dataProblem struct {
+	Course string
+	Stage  string
+	Err    error
+	Effect string // What the report shows instead, e.g. "no grade impact"
+}
+
+// kind names the failure for display and JSON.
+func (p dataProblem) kind() string {
+	for _, kind := range []error{ErrAuth, ErrForbidden, ErrNotFound, ErrRateLimited, ErrDecode} {
+		if errors.Is(p.Err, kind) {
+			return kind.Error()
+		}
+	}
+	var apiErr *APIError
+	if errors.As(p.Err, &apiErr) && apiErr.StatusCode >= 500 {
+		return "server error"
+	}
+	return "error"
+}
+
+// where names the course and stage that failed.
+func (p dataProblem) where() string {
+	where := p.Course
+	if where == "" {
+		where = "All courses"
+	}
+	if p.Stage != "" {
+		where += " - " + p.Stage
+	}
+	return where
+}
+
+// detail is the error and what the report shows instead.
+func (p dataProblem) detail() string {
+	detail := p.Err.Error()
+	if p.Effect != "" {
+		detail += " (" + p.Effect + ")"
+	}
+	return detail
+}
+
+// problemLog collects data problems from concurrent fetches.
+type problemLog struct {
+	mu       sync.Mutex
+	problems []dataProblem
+}
+
+// add records err against a course, taking the stage from a stageError. The
+// assignment and grade fetches share some endpoints, so a repeated failure
+// only adds its effect to the existing problem.
+func (l *problemLog) add(course string, err error, effect string) {
+	p := dataProblem{Course: course, Err: err, Effect: effect}
+	var se *stageError
+	if errors.As(err, &se) {
+		p.Stage, p.Err = se.stage, se.err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, seen := range l.problems {
+		if seen.Course == p.Course && seen.Stage == p.Stage && seen.Err.Error() == p.Err.Error() {
+			if effect != "" && !strings.Contains(seen.Effect, effect) {
+				l.problems[i].Effect = strings.TrimPrefix(seen.Effect+"; "+effect, "; ")
+			}
+			return
+		}
+	}
+	l.problems = append(l.problems, p)
+}
+
+// list returns the problems sorted by course and stage.
+func (l *problemLog) list() []dataProblem {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		if l.problems[i].Course != l.problems[j].Course {
+			return l.problems[i].Course < l.problems[j].Course
+		}
+		return l.problems[i].Stage < l.problems[j].Stage
+	})
+	return l.problems
+}
+
+// printProblems prints the DATA PROBLEMS section so a partial report can't
+// be mistaken for a complete one.
+func printProblems(w io.Writer, problems []dataProblem) {
+	yellow := color.New(color.FgYellow, color.Bold)
+	dim := color.New(color.Faint)
+
+	yellow.Fprintf(w, "DATA PROBLEMS (%d)\n", len(problems))
+	for _, p := range problems {
+		fmt.Fprintf(w, "  %s: %s\n", p.where(), p.kind())
+		dim.Fprintf(w, "    %s\n", p.detail())
+	}
+	dim.Fprintln(w, "  Run 'canvas-report doctor' for likely causes.")
+}
@@ -0,0 +1,162 @@
+// ABOUTME: CSV and TSV export of assignments and grades for canvas-report.
+// ABOUTME: Writes every dated assignment, or every course and category grade, as spreadsheet rows.
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"os"
+	"strconv"
+	"time"
+)
+
+var assignmentColumns = []string{
+	"student", "course", "course_id", "assignment", "assignment_id", "category",
+	"due_at", "points_possible", "score", "status", "submitted_at", "graded_at",
+	"impact_gain", "impact_loss", "url", "late", "seconds_late", "points_deducted", "late_penalty",
+}
+
+var gradeColumns = []string{
+	"student", "period", "period_start", "period_end", "course", "course_id",
+	"category", "percent", "points", "points_possible", "weight",
+}
+
+func exportFlags(c *cli, fs *flag.FlagSet) {
+	fs.BoolVar(&c.exportGrades, "grades", false, "export course and category grades instead of assignments")
+	fs.BoolVar(&c.tsv, "tsv", false, "separate columns with tabs instead of commas")
+	strictFlag(c, fs)
+}
+
+func runExport(c *cli, args []string) error {
+	sections := assignmentSections
+	if c.exportGrades {
+		sections = []string{sectionGrades}
+	}
+	// Every past assignment, however old, should get its missing status
+	c.showAll = true
+	reports, err := c.loadReports(args, sections)
+	if err != nil {
+		return err
+	}
+	var students []studentData
+	for _, r := range reports {
+		collected, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		students = append(students, collected...)
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	if c.tsv {
+		w.Comma = '\t'
+	}
+	if c.exportGrades {
+		writeGradeRows(w, students)
+	} else {
+		writeAssignmentRows(w, students)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, data := range students {
+		problems += len(data.problems)
+	}
+	return reports[0].checkStrict(problems)
+}
+
+func writeAssignmentRows(w *csv.Writer, students []studentData) {
+	w.Write(assignmentColumns)
+	for _, data := range students {
+		for _, a := range data.assignments {
+			row := []string{
+				data.name, a.CourseName, strconv.Itoa(a.CourseID), a.Name, strconv.Itoa(a.ID), a.CategoryName,
+				a.DueAt.Format(time.RFC3339), csvFloat(a.PointsPossible, -1), "", exportStatus(a), "", "",
+				"", "", a.URL, "", "", "", "",
+			}
+			if sub := a.Submission; sub != nil {
+				row[8] = csvFloat(sub.Score, -1)
+				row[10] = csvTime(sub.SubmittedAt)
+				row[11] = csvTime(sub.GradedAt)
+				row[15] = strconv.FormatBool(submittedLate(sub))
+				row[16] = strconv.FormatInt(sub.SecondsLate, 10)
+				row[17] = csvFloat(sub.PointsDeducted, -1)
+			}
+			if a.Impact != nil && !isCompleted(a.Submission) {
+				row[12] = strconv.FormatFloat(a.Impact.Gain, 'f', 2, 64)
+				row[13] = strconv.FormatFloat(a.Impact.Loss, 'f', 2, 64)
+				row[18] = strconv.FormatFloat(a.Impact.Penalty, 'f', 2, 64)
+			}
+			w.Write(row)
+		}
+	}
+}
+
+func writeGradeRows(w *csv.Writer, students []studentData) {
+	w.Write(gradeColumns)
+	for _, data := range students {
+		for _, pg := range data.grades {
+			period := []string{pg.period.Title, csvTime(pg.period.StartDate), csvTime(pg.period.EndDate)}
+			for _, g := range pg.grades {
+				row := append([]string{data.name}, period...)
+				row = append(row, g.CourseName, strconv.Itoa(g.CourseID), "", strconv.FormatFloat(g.Percent, 'f', 2, 64))
+				if g.Weighted {
+					row = append(row, "", "", "")
+				} else {
+					row = append(row, csvFloat(&g.Points, 2), csvFloat(&g.PointsPossible, 2), "")
+				}
+				w.Write(row)
+				for _, cat := range g.Categories {
+					row := append([]string{data.name}, period...)
+					row = append(row, g.CourseName, strconv.Itoa(g.CourseID), cat.Name,
+						strconv.FormatFloat(cat.Percent, 'f', 2, 64), csvFloat(&cat.Points, 2), csvFloat(&cat.PointsPossible, 2),
+						strconv.FormatFloat(cat.Weight, 'f', -1, 64))
+					w.Write(row)
+				}
+			}
+		}
+	}
+}
+
+// exportStatus names where an assignment stands: the missing sections'
+// status when it has one, otherwise what its submission shows.
+func exportStatus(a EnrichedAssignment) string {
+	sub := a.Submission
+	switch {
+	case a.Status != "":
+		return a.Status
+	case sub != nil && sub.Excused:
+		return "Excused"
+	case sub != nil && sub.GradedAt != nil && submittedLate(sub):
+		return "Graded, submitted late"
+	case sub != nil && sub.GradedAt != nil:
+		return "Graded"
+	case submittedLate(sub):
+		return "Submitted late"
+	case sub != nil && sub.SubmittedAt != nil:
+		return "Submitted"
+	case a.DueAt.After(time.Now()):
+		return "Upcoming"
+	}
+	return "Not submitted"
+}
+
+// csvFloat formats an optional number, empty when it's absent.
+func csvFloat(v *float64, precision int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', precision, 64)
+}
+
+// csvTime formats an optional time in ISO 8601, empty when it's absent.
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,122 @@
+// ABOUTME: Tests for canvas-report CSV/TSV export.
+// ABOUTME: Checks every row lines up with its header and the late work columns are filled in.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+func TestExportRowsMatchHeader(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	due := time.Date(2026, time.October, 12, 23, 59, 0, 0, time.UTC)
+	submitted := due.Add(2 * time.Hour)
+	graded := due.Add(48 * time.Hour)
+
+	data := studentData{
+		name: "Jane Doe",
+		assignments: []EnrichedAssignment{
+			{ID: 1, Name: "No submission", DueAt: due, PointsPossible: ptr(20)},
+			{
+				ID: 2, Name: "Missing with a penalty", DueAt: due, PointsPossible: ptr(20), Status: "Missing",
+				Submission: &Submission{AssignmentID: 2, Missing: true},
+				Impact:     &AssignmentImpact{Gain: 1.5, Loss: 3, Penalty: 20},
+			},
+			{
+				ID: 3, Name: "Turned in late", DueAt: due, PointsPossible: ptr(5),
+				Submission: &Submission{AssignmentID: 3, SubmittedAt: &submitted, GradedAt: &graded, Score: ptr(4.5),
+					Late: true, SecondsLate: 7200, PointsDeducted: ptr(0.5)},
+				Impact: &AssignmentImpact{Gain: 0.4},
+			},
+		},
+		grades: []periodGrades{{
+			period: GradingPeriod{Title: "Q1"},
+			grades: []CourseGrade{{CourseName: "Math", CourseID: 102, Percent: 91, Weighted: true,
+				Categories: []CategoryGrade{{Name: "Tests", Percent: 90, Points: 45, PointsPossible: 50, Weight: 60}}}},
+		}},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		header []string
+		write  func(*csv.Writer, []studentData)
+		rows   int
+	}{
+		{"assignments", assignmentColumns, writeAssignmentRows, 3},
+		{"grades", gradeColumns, writeGradeRows, 2},
+	} {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		tt.write(w, []studentData{data})
+		w.Flush()
+
+		r := csv.NewReader(&buf)
+		r.FieldsPerRecord = -1 // Check the counts here, with a clearer message
+		records, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(records) != tt.rows+1 {
+			t.Fatalf("%s: got %d records, want a header and %d rows", tt.name, len(records), tt.rows)
+		}
+		for i, rec := range records {
+			if len(rec) != len(tt.header) {
+				t.Errorf("%s: record %d has %d fields, header has %d: %q", tt.name, i, len(rec), len(tt.header), rec)
+			}
+		}
+	}
+}
+
+func TestExportLateColumns(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	due := time.Date(2026, time.October, 12, 23, 59, 0, 0, time.UTC)
+	submitted := due.Add(2 * time.Hour)
+
+	tests := []struct {
+		name string
+		a    EnrichedAssignment
+		want map[string]string
+	}{
+		{
+			"no submission or impact",
+			EnrichedAssignment{DueAt: due},
+			map[string]string{"late": "", "seconds_late": "", "points_deducted": "", "late_penalty": ""},
+		},
+		{
+			"missing, penalty ahead",
+			EnrichedAssignment{DueAt: due, Submission: &Submission{Missing: true}, Impact: &AssignmentImpact{Gain: 1.5, Penalty: 20}},
+			map[string]string{"late": "false", "seconds_late": "0", "points_deducted": "", "late_penalty": "20.00"},
+		},
+		{
+			"turned in late with a deduction",
+			EnrichedAssignment{DueAt: due, Submission: &Submission{SubmittedAt: &submitted, Late: true, SecondsLate: 7200, PointsDeducted: ptr(0.5)},
+				Impact: &AssignmentImpact{Gain: 0.4, Penalty: 10}},
+			// Work already in has no gain or penalty left to show
+			map[string]string{"late": "true", "seconds_late": "7200", "points_deducted": "0.5", "late_penalty": ""},
+		},
+	}
+
+	column := make(map[string]int)
+	for i, name := range assignmentColumns {
+		column[name] = i
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		writeAssignmentRows(w, []studentData{{name: "Jane Doe", assignments: []EnrichedAssignment{tt.a}}})
+		w.Flush()
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		row := records[1]
+		for name, want := range tt.want {
+			if got := row[column[name]]; got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// ABOUTME: Student and course filtering for canvas-report.
+// ABOUTME: Matches observees and courses against include/exclude patterns from config and flags.
+
+package main
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Filter is a pair of include/exclude pattern lists. A pattern is a Canvas ID,
+// a glob (when it contains *, ? or [), or otherwise a case-insensitive
+// substring of the name.
+type Filter struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// Filters holds every filter that applies to a report run.
+type Filters struct {
+	Students   Filter
+	Courses    Filter
+	PerStudent map[string]Filter // Course filters keyed by student name pattern
+}
+
+// Allows reports whether a name (or ID) passes the filter. An empty include
+// list allows everything that isn't excluded.
+func (f Filter) Allows(id int, names ...string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, id, names) {
+		return false
+	}
+	return !matchAny(f.Exclude, id, names)
+}
+
+// Merge returns f with other layered on top: other's includes replace f's
+// (a flag is more specific than config), excludes accumulate.
+func (f Filter) Merge(other Filter) Filter {
+	merged := Filter{Include: f.Include}
+	if len(other.Include) > 0 {
+		merged.Include = other.Include
+	}
+	merged.Exclude = append(append([]string(nil), f.Exclude...), other.Exclude...)
+	return merged
+}
+
+func (f Filters) includeStudent(student Observee) bool {
+	return f.Students.Allows(student.ID, student.Name, student.ShortName)
+}
+
+func (f Filters) includeCourse(student Observee, course Course) bool {
+	if !f.Courses.Allows(course.ID, course.Name, course.OriginalName) {
+		return false
+	}
+	for pattern, filter := range f.PerStudent {
+		if !matchPattern(pattern, student.ID, []string{student.Name, student.ShortName}) {
+			continue
+		}
+		if !filter.Allows(course.ID, course.Name, course.OriginalName) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filters) filterStudents(students []Observee) []Observee {
+	var result []Observee
+	for _, s := range students {
+		if f.includeStudent(s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (f Filters) filterCourses(student Observee, courses []Course) []Course {
+	var result []Course
+	for _, c := range courses {
+		if f.includeCourse(student, c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func matchAny(patterns []string, id int, names []string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, id, names) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern string, id int, names []string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return false
+	}
+	if n, err := strconv.Atoi(pattern); err == nil {
+		return n == id
+	}
+
+	isGlob := strings.ContainsAny(pattern, "*?[")
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if name == "" {
+			continue
+		}
+		if isGlob {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		} else if strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
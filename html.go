@@ -0,0 +1,253 @@
+// ABOUTME: HTML rendering of the report for canvas-report.
+// ABOUTME: Produces self-contained tables with inline styles, since email clients ignore style sheets.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// Inline styles shared by the report template.
+const (
+	htmlBody  = "font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#222"
+	htmlTable = "border-collapse:collapse;font-size:14px;margin:4px 0 16px 0"
+	htmlCell  = "border:1px solid #ddd;padding:4px 8px;text-align:left"
+	htmlNum   = "border:1px solid #ddd;padding:4px 8px;text-align:right"
+	htmlHead  = "border:1px solid #ddd;padding:4px 8px;text-align:left;background:#f4f4f4"
+	htmlDim   = "color:#888"
+	htmlLink  = "color:inherit"
+)
+
+var htmlSectionColors = map[string]string{
+	sectionMissing:     "#c0392b",
+	sectionUnconfirmed: "#888888",
+	sectionUpcoming:    "#b7950b",
+	sectionWeekAhead:   "#1f8a99",
+}
+
+type htmlStudent struct {
+	Name     string
+	Sections []htmlSection
+	Grades   []htmlPeriod
+	Problems []htmlProblem
+	Summary  string
+}
+
+type htmlProblem struct {
+	Where, Kind, Detail string
+}
+
+type htmlSection struct {
+	Kind  string
+	Title string
+	Color string
+	Empty string
+	Rows  []htmlAssignment
+}
+
+type htmlAssignment struct {
+	Course, Name, Category, Due, Pts, Impact, Status string
+	URL, CourseURL                                   string
+	Done                                             bool
+}
+
+type htmlPeriod struct {
+	Title string
+	Rows  []htmlGradeRow
+}
+
+type htmlGradeRow struct {
+	Name, Percent, Points, Possible, Weight string
+	URL                                     string
+	Category                                bool
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"style": func(s string) template.CSS { return template.CSS(s) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Canvas report</title></head>
+<body style="{{style $.Body}}">
+{{template "students" .}}
+</body></html>
+{{define "students"}}{{range .Students}}
+<h2 style="margin:24px 0 0 0">{{.Name}}</h2>
+<p style="{{style "color:#888;margin:0 0 12px 0"}}">Generated {{$.Generated}}</p>
+{{range .Sections}}
+<h3 style="color:{{.Color}};margin:12px 0 4px 0">{{.Title}}</h3>
+{{if not .Rows}}<p style="{{style "color:#888"}}">{{.Empty}}</p>{{else}}
+<table style="{{style $.Table}}">
+<tr><th style="{{style $.Head}}">Subject</th><th style="{{style $.Head}}">Assignment</th><th style="{{style $.Head}}">Due</th><th style="{{style $.Head}}">Pts</th><th style="{{style $.Head}}">Impact</th><th style="{{style $.Head}}"></th></tr>
+{{range .Rows}}<tr{{if .Done}} style="{{style $.Dim}}"{{end}}>
+<td style="{{style $.Cell}}">{{if .CourseURL}}<a href="{{.CourseURL}}" style="{{style $.Link}}">{{.Course}}</a>{{else}}{{.Course}}{{end}}</td>
+<td style="{{style $.Cell}}">{{if .URL}}<a href="{{.URL}}" style="{{style $.Link}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}{{if .Category}} <span style="{{style $.Dim}}">({{.Category}})</span>{{end}}</td>
+<td style="{{style $.Cell}}">{{.Due}}</td>
+<td style="{{style $.Num}}">{{.Pts}}</td>
+<td style="{{style $.Num}}">{{.Impact}}</td>
+<td style="{{style $.Cell}}">{{.Status}}</td>
+</tr>
+{{end}}</table>{{end}}
+{{end}}
+{{range .Grades}}
+<h3 style="color:#8e44ad;margin:12px 0 4px 0">GRADES - {{.Title}}</h3>
+<table style="{{style $.Table}}">
+<tr><th style="{{style $.Head}}">Subject</th><th style="{{style $.Head}}">%</th><th style="{{style $.Head}}">Points</th><th style="{{style $.Head}}">Possible</th><th style="{{style $.Head}}">Weight</th></tr>
+{{range .Rows}}<tr{{if .Category}} style="{{style $.Dim}}"{{end}}>
+<td style="{{style $.Cell}}">{{if .Category}}&nbsp;&nbsp;{{end}}{{if .URL}}<a href="{{.URL}}" style="{{style $.Link}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td>
+<td style="{{style $.Num}}">{{.Percent}}</td>
+<td style="{{style $.Num}}">{{.Points}}</td>
+<td style="{{style $.Num}}">{{.Possible}}</td>
+<td style="{{style $.Num}}">{{.Weight}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+{{if .Problems}}
+<h3 style="color:#b7950b;margin:12px 0 4px 0">DATA PROBLEMS ({{len .Problems}})</h3>
+<ul>{{range .Problems}}
+<li>{{.Where}}: {{.Kind}}<br><span style="{{style $.Dim}}">{{.Detail}}</span></li>
+{{end}}</ul>
+{{end}}
+{{if .Summary}}<p><strong>{{.Summary}}</strong></p>{{end}}
+<hr style="border:none;border-top:1px solid #ddd">
+{{end}}{{end}}
+`))
+
+// htmlData is what the report template renders: the students plus the
+// shared inline styles.
+type htmlData struct {
+	Generated                               string
+	Students                                []htmlStudent
+	Body, Table, Cell, Num, Head, Dim, Link string
+}
+
+func newHTMLData(students []studentData, generated time.Time) htmlData {
+	data := htmlData{
+		Generated: generated.Local().Format("Mon Jan 2, 2006 at 3:04 PM"),
+		Body:      htmlBody,
+		Table:     htmlTable,
+		Cell:      htmlCell,
+		Num:       htmlNum,
+		Head:      htmlHead,
+		Dim:       htmlDim,
+		Link:      htmlLink,
+	}
+	for _, s := range students {
+		data.Students = append(data.Students, newHTMLStudent(s))
+	}
+	return data
+}
+
+// renderHTML writes the students' reports as a standalone HTML document.
+func renderHTML(w io.Writer, students []studentData) error {
+	return htmlTemplate.Execute(w, newHTMLData(students, time.Now()))
+}
+
+func newHTMLStudent(data studentData) htmlStudent {
+	hs := htmlStudent{Name: data.name}
+
+	for _, sec := range data.sections {
+		// Optional sections only appear when they have something to show
+		if len(sec.assignments) == 0 && (sec.kind == sectionUnconfirmed || sec.kind == sectionWeekAhead) {
+			continue
+		}
+		missing := sec.kind == sectionMissing || sec.kind == sectionUnconfirmed
+		hsec := htmlSection{
+			Kind:  sec.kind,
+			Title: fmt.Sprintf("%s (%d)", sec.title, len(sec.assignments)),
+			Color: htmlSectionColors[sec.kind],
+			Empty: sec.empty,
+		}
+		switch sec.kind {
+		case sectionMissing:
+			if len(sec.assignments) == 0 {
+				hsec.Color = "#27ae60"
+			}
+		case sectionUpcoming:
+			hsec.Title = fmt.Sprintf("%s (%d pending)", sec.title, sec.pending)
+		case sectionWeekAhead:
+			hsec.Title = fmt.Sprintf("%s (%d pending)", sec.title, sec.pending)
+		}
+		for _, a := range sec.assignments {
+			hsec.Rows = append(hsec.Rows, newHTMLAssignment(a, missing))
+		}
+		hs.Sections = append(hs.Sections, hsec)
+	}
+
+	for _, pg := range data.grades {
+		title := pg.period.Title
+		if title == "" {
+			title = "Current Period"
+		}
+		if pg.period.StartDate != nil && pg.period.EndDate != nil {
+			title += fmt.Sprintf(" (%s - %s)", pg.period.StartDate.Local().Format("Jan 2"), pg.period.EndDate.Local().Format("Jan 2"))
+		}
+		hp := htmlPeriod{Title: title}
+		for _, g := range pg.grades {
+			row := htmlGradeRow{Name: g.CourseName, URL: g.URL, Percent: fmt.Sprintf("%.2f%%", g.Percent)}
+			if !g.Weighted {
+				row.Points = fmt.Sprintf("%.0f", g.Points)
+				row.Possible = fmt.Sprintf("%.0f", g.PointsPossible)
+			}
+			hp.Rows = append(hp.Rows, row)
+			for _, cat := range g.Categories {
+				hp.Rows = append(hp.Rows, htmlGradeRow{
+					Name:     cat.Name,
+					Percent:  fmt.Sprintf("%.2f%%", cat.Percent),
+					Points:   fmt.Sprintf("%.0f", cat.Points),
+					Possible: fmt.Sprintf("%.0f", cat.PointsPossible),
+					Weight:   fmt.Sprintf("%.0f%%", cat.Weight),
+					Category: true,
+				})
+			}
+		}
+		hs.Grades = append(hs.Grades, hp)
+	}
+
+	for _, p := range data.problems {
+		hs.Problems = append(hs.Problems, htmlProblem{Where: p.where(), Kind: p.kind(), Detail: p.detail()})
+	}
+
+	st := newCheckStatus(data)
+	hs.Summary = strings.TrimPrefix(st.line(true), data.name+": ")
+	return hs
+}
+
+func newHTMLAssignment(a EnrichedAssignment, missing bool) htmlAssignment {
+	row := htmlAssignment{
+		Course:    a.CourseName,
+		Name:      a.Name,
+		Category:  a.CategoryName,
+		Due:       strings.ToLower(a.DueAt.Local().Format("Mon 1/2 3pm")),
+		Impact:    formatImpact(a.Impact),
+		URL:       a.URL,
+		CourseURL: a.CourseURL,
+	}
+	if a.PointsPossible != nil {
+		row.Pts = fmt.Sprintf("%d", int(*a.PointsPossible))
+	}
+
+	switch {
+	case missing:
+		switch a.Status {
+		case "Missing":
+			row.Status = "✗"
+		case "Marked missing":
+			row.Status = "!"
+		case "Unconfirmed":
+			row.Status = "?"
+		default:
+			row.Status = "0"
+		}
+	case isCompleted(a.Submission):
+		row.Done = true
+		row.Status = "✓"
+		row.Impact = formatDeduction(a.Submission)
+		if submittedLate(a.Submission) {
+			row.Status = "L"
+		}
+	}
+	return row
+}
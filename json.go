@@ -0,0 +1,197 @@
+// ABOUTME: JSON output for canvas-report.
+// ABOUTME: Converts collected student data into a stable, documented JSON shape for scripting.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+type jsonReport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Students    []jsonStudent `json:"students"`
+}
+
+type jsonStudent struct {
+	Name     string        `json:"name"`
+	Sections []jsonSection `json:"sections,omitempty"`
+	Grades   []jsonPeriod  `json:"grades,omitempty"`
+	Problems []jsonProblem `json:"problems,omitempty"`
+}
+
+type jsonProblem struct {
+	Course     string `json:"course,omitempty"`
+	Stage      string `json:"stage,omitempty"`
+	Kind       string `json:"kind"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Message    string `json:"message"`
+	Effect     string `json:"effect,omitempty"`
+}
+
+type jsonSection struct {
+	Kind        string           `json:"kind"`
+	Title       string           `json:"title"`
+	Pending     int              `json:"pending"`
+	Assignments []jsonAssignment `json:"assignments"`
+}
+
+type jsonAssignment struct {
+	ID             int         `json:"id"`
+	CourseID       int         `json:"course_id"`
+	Course         string      `json:"course"`
+	Name           string      `json:"name"`
+	Category       string      `json:"category,omitempty"`
+	DueAt          time.Time   `json:"due_at"`
+	PointsPossible *float64    `json:"points_possible"`
+	Status         string      `json:"status,omitempty"`
+	Completed      bool        `json:"completed"`
+	SubmittedAt    *time.Time  `json:"submitted_at,omitempty"`
+	GradedAt       *time.Time  `json:"graded_at,omitempty"`
+	Score          *float64    `json:"score,omitempty"`
+	Late           bool        `json:"late"`
+	SecondsLate    int64       `json:"seconds_late,omitempty"`
+	PointsDeducted *float64    `json:"points_deducted,omitempty"`
+	Impact         *jsonImpact `json:"impact,omitempty"`
+	URL            string      `json:"url"`
+}
+
+type jsonImpact struct {
+	Gain     float64 `json:"gain"`
+	Loss     float64 `json:"loss"`
+	Weighted bool    `json:"weighted"`
+	Penalty  float64 `json:"late_penalty,omitempty"` // Percent of the points a late turn-in loses, already taken out of gain
+}
+
+type jsonPeriod struct {
+	Title     string       `json:"title"`
+	StartDate *time.Time   `json:"start_date,omitempty"`
+	EndDate   *time.Time   `json:"end_date,omitempty"`
+	Courses   []jsonCourse `json:"courses"`
+}
+
+type jsonCourse struct {
+	CourseID       int            `json:"course_id"`
+	Course         string         `json:"course"`
+	URL            string         `json:"url"`
+	Percent        float64        `json:"percent"`
+	Points         *float64       `json:"points,omitempty"`
+	PointsPossible *float64       `json:"points_possible,omitempty"`
+	Categories     []jsonCategory `json:"categories,omitempty"`
+}
+
+type jsonCategory struct {
+	Name           string  `json:"name"`
+	Percent        float64 `json:"percent"`
+	Points         float64 `json:"points"`
+	PointsPossible float64 `json:"points_possible"`
+	Weight         float64 `json:"weight"`
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func reportJSON(students []studentData) jsonReport {
+	out := jsonReport{GeneratedAt: time.Now(), Students: []jsonStudent{}}
+	for _, data := range students {
+		out.Students = append(out.Students, studentJSON(data))
+	}
+	return out
+}
+
+func studentJSON(data studentData) jsonStudent {
+	student := jsonStudent{Name: data.name}
+	for _, sec := range data.sections {
+		js := jsonSection{
+			Kind:        sec.kind,
+			Title:       sec.title,
+			Pending:     sec.pending,
+			Assignments: []jsonAssignment{},
+		}
+		for _, a := range sec.assignments {
+			js.Assignments = append(js.Assignments, assignmentJSON(a))
+		}
+		student.Sections = append(student.Sections, js)
+	}
+	for _, pg := range data.grades {
+		student.Grades = append(student.Grades, periodJSON(pg))
+	}
+	student.Problems = problemsJSON(data.problems)
+	return student
+}
+
+func problemsJSON(problems []dataProblem) []jsonProblem {
+	var out []jsonProblem
+	for _, p := range problems {
+		jp := jsonProblem{Course: p.Course, Stage: p.Stage, Kind: p.kind(), Message: p.Err.Error(), Effect: p.Effect}
+		var apiErr *APIError
+		if errors.As(p.Err, &apiErr) {
+			jp.StatusCode = apiErr.StatusCode
+		}
+		out = append(out, jp)
+	}
+	return out
+}
+
+func assignmentJSON(a EnrichedAssignment) jsonAssignment {
+	ja := jsonAssignment{
+		ID:             a.ID,
+		CourseID:       a.CourseID,
+		Course:         a.CourseName,
+		Name:           a.Name,
+		Category:       a.CategoryName,
+		DueAt:          a.DueAt,
+		PointsPossible: a.PointsPossible,
+		Status:         a.Status,
+		Completed:      isCompleted(a.Submission),
+		URL:            a.URL,
+	}
+	if sub := a.Submission; sub != nil {
+		ja.SubmittedAt = sub.SubmittedAt
+		ja.GradedAt = sub.GradedAt
+		ja.Score = sub.Score
+		ja.Late = submittedLate(sub)
+		if ja.Late {
+			ja.SecondsLate = sub.SecondsLate
+			ja.PointsDeducted = sub.PointsDeducted
+		}
+	}
+	if a.Impact != nil {
+		ja.Impact = &jsonImpact{Gain: a.Impact.Gain, Loss: a.Impact.Loss, Weighted: a.Impact.IsWeighted, Penalty: a.Impact.Penalty}
+	}
+	return ja
+}
+
+func periodJSON(pg periodGrades) jsonPeriod {
+	jp := jsonPeriod{
+		Title:     pg.period.Title,
+		StartDate: pg.period.StartDate,
+		EndDate:   pg.period.EndDate,
+		Courses:   []jsonCourse{},
+	}
+	for _, g := range pg.grades {
+		jc := jsonCourse{CourseID: g.CourseID, Course: g.CourseName, URL: g.URL, Percent: g.Percent}
+		if g.Weighted {
+			for _, cat := range g.Categories {
+				jc.Categories = append(jc.Categories, jsonCategory{
+					Name:           cat.Name,
+					Percent:        cat.Percent,
+					Points:         cat.Points,
+					PointsPossible: cat.PointsPossible,
+					Weight:         cat.Weight,
+				})
+			}
+		} else {
+			points, possible := g.Points, g.PointsPossible
+			jc.Points = &points
+			jc.PointsPossible = &possible
+		}
+		jp.Courses = append(jp.Courses, jc)
+	}
+	return jp
+}
@@ -0,0 +1,83 @@
+// ABOUTME: Late work for canvas-report.
+// ABOUTME: Applies a course's late policy to estimate deductions and formats how late and how costly a submission was.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// penalty returns the fraction of the points possible the policy deducts
+// from work turned in late by the given amount.
+func (p *LatePolicy) penalty(late time.Duration) float64 {
+	if p == nil || !p.DeductionEnabled || p.Deduction <= 0 || late <= 0 {
+		return 0
+	}
+	interval := 24 * time.Hour
+	if p.Interval == "hour" {
+		interval = time.Hour
+	}
+	// Canvas counts a partial interval as a whole one
+	intervals := math.Ceil(float64(late) / float64(interval))
+	percent := intervals * p.Deduction
+	if p.MinimumPercentEnabled {
+		percent = math.Min(percent, 100-p.MinimumPercent)
+	}
+	return math.Min(math.Max(percent, 0), 100) / 100
+}
+
+// lateness is how late an assignment is or would be: how late it was turned
+// in, or for unsubmitted work, how far past due it is now. Zero when it
+// isn't late.
+func lateness(sub *Submission, due *time.Time, now time.Time) time.Duration {
+	switch {
+	case sub != nil && sub.Excused, sub != nil && sub.LatePolicyStatus == "extended", sub != nil && sub.LatePolicyStatus == "none":
+		return 0
+	case sub != nil && sub.SubmittedAt != nil:
+		return time.Duration(sub.SecondsLate) * time.Second
+	case due == nil || now.Before(*due):
+		return 0
+	}
+	return now.Sub(*due)
+}
+
+// submittedLate reports whether work was turned in after it was due.
+func submittedLate(sub *Submission) bool {
+	return sub != nil && isCompleted(sub) && !sub.Excused && (sub.Late || sub.LatePolicyStatus == "late")
+}
+
+// pointsDeducted is how many points the late policy took off, or zero.
+func pointsDeducted(sub *Submission) float64 {
+	if sub == nil || sub.PointsDeducted == nil {
+		return 0
+	}
+	return *sub.PointsDeducted
+}
+
+// formatDeduction shows deducted points, like "-1.5 pts", or nothing when
+// none were taken.
+func formatDeduction(sub *Submission) string {
+	points := pointsDeducted(sub)
+	if points <= 0 {
+		return ""
+	}
+	return "-" + strconv.FormatFloat(points, 'f', -1, 64) + " pts"
+}
+
+// formatLateness shows how late a submission was, like "2h late" or
+// "3d late".
+func formatLateness(seconds int64) string {
+	late := time.Duration(seconds) * time.Second
+	switch {
+	case late <= 0:
+		return "late"
+	case late < time.Hour:
+		return fmt.Sprintf("%dm late", int(late.Minutes()+0.5))
+	case late < 24*time.Hour:
+		return fmt.Sprintf("%dh late", int(late.Hours()+0.5))
+	}
+	return fmt.Sprintf("%dd late", int(late.Hours()/24+0.5))
+}
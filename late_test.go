@@ -0,0 +1,164 @@
+// ABOUTME: Tests for canvas-report late work.
+// ABOUTME: Covers late policy deductions, how late work is, and how the penalty lowers an assignment's possible gain.
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLatePolicyPenalty(t *testing.T) {
+	daily := func(deduction float64) *LatePolicy {
+		return &LatePolicy{DeductionEnabled: true, Deduction: deduction, Interval: "day"}
+	}
+	floored := func(deduction, minimum float64) *LatePolicy {
+		p := daily(deduction)
+		p.MinimumPercentEnabled, p.MinimumPercent = true, minimum
+		return p
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		policy *LatePolicy
+		late   time.Duration
+		want   float64
+	}{
+		{"nil policy", nil, 3 * day, 0},
+		{"deduction disabled", &LatePolicy{Deduction: 10, Interval: "day"}, 3 * day, 0},
+		{"zero deduction", daily(0), 3 * day, 0},
+		{"negative deduction", daily(-10), 3 * day, 0},
+		{"on time", daily(10), 0, 0},
+		{"early", daily(10), -time.Hour, 0},
+		{"a second late is a whole day", daily(10), time.Second, 0.10},
+		{"exactly one day", daily(10), day, 0.10},
+		{"just over one day", daily(10), day + time.Second, 0.20},
+		{"three days", daily(10), 3 * day, 0.30},
+		{"unknown interval counts days", &LatePolicy{DeductionEnabled: true, Deduction: 10}, 2 * day, 0.20},
+		{"hourly", &LatePolicy{DeductionEnabled: true, Deduction: 5, Interval: "hour"}, 90 * time.Minute, 0.10},
+		{"hourly over a day", &LatePolicy{DeductionEnabled: true, Deduction: 1, Interval: "hour"}, day, 0.24},
+		{"below the floor", floored(10, 50), 2 * day, 0.20},
+		{"at the floor", floored(10, 50), 5 * day, 0.50},
+		{"floor caps the deduction", floored(10, 50), 8 * day, 0.50},
+		{"floor of zero", floored(25, 0), 8 * day, 1},
+		{"floor set but disabled", &LatePolicy{DeductionEnabled: true, Deduction: 10, Interval: "day", MinimumPercent: 50}, 8 * day, 0.80},
+		{"never more than everything", daily(10), 15 * day, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.penalty(tt.late); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: penalty(%s) = %g, want %g", tt.name, tt.late, got, tt.want)
+		}
+	}
+}
+
+func TestLateness(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	due := now.Add(-50 * time.Hour)
+	future := now.Add(time.Hour)
+	submitted := now.Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		sub  *Submission
+		due  *time.Time
+		want time.Duration
+	}{
+		{"no submission, past due", nil, &due, 50 * time.Hour},
+		{"no submission, not due yet", nil, &future, 0},
+		{"no due date", nil, nil, 0},
+		{"unsubmitted, past due", &Submission{}, &due, 50 * time.Hour},
+		{"submitted late", &Submission{SubmittedAt: &submitted, SecondsLate: 7200}, &due, 2 * time.Hour},
+		{"submitted on time", &Submission{SubmittedAt: &submitted}, &due, 0},
+		{"excused", &Submission{Excused: true}, &due, 0},
+		{"extended by the teacher", &Submission{LatePolicyStatus: "extended"}, &due, 0},
+		{"late policy waived", &Submission{LatePolicyStatus: "none"}, &due, 0},
+	}
+	for _, tt := range tests {
+		if got := lateness(tt.sub, tt.due, now); got != tt.want {
+			t.Errorf("%s: lateness = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLateImpactGain(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	ptr := func(v float64) *float64 { return &v }
+	daysAgo := func(n int) *time.Time {
+		d := now.AddDate(0, 0, -n)
+		return &d
+	}
+	daily := &LatePolicy{DeductionEnabled: true, Deduction: 10, Interval: "day"}
+	floored := &LatePolicy{DeductionEnabled: true, Deduction: 10, Interval: "day", MinimumPercentEnabled: true, MinimumPercent: 80}
+
+	// One category: 50 of 100 points graded, a 100 point assignment missing
+	// and another not due for two days
+	single := []AssignmentGroup{{ID: 1, Assignments: []AssignmentInGroup{
+		{ID: 10, PointsPossible: ptr(100), DueAt: daysAgo(20)},
+		{ID: 11, PointsPossible: ptr(100), DueAt: daysAgo(3)},
+		{ID: 12, PointsPossible: ptr(100), DueAt: daysAgo(-2)},
+	}}}
+	singleSubs := []Submission{{AssignmentID: 10, Score: ptr(50), GradedAt: daysAgo(19)}}
+
+	// Two equally weighted categories at 80% and 60%, with a 50 point
+	// assignment missing from the second
+	weighted := []AssignmentGroup{
+		{ID: 1, GroupWeight: 50, Assignments: []AssignmentInGroup{{ID: 20, PointsPossible: ptr(50), DueAt: daysAgo(20)}}},
+		{ID: 2, GroupWeight: 50, Assignments: []AssignmentInGroup{
+			{ID: 21, PointsPossible: ptr(50), DueAt: daysAgo(20)},
+			{ID: 22, PointsPossible: ptr(50), DueAt: daysAgo(8)},
+		}},
+	}
+	weightedSubs := []Submission{
+		{AssignmentID: 20, Score: ptr(40), GradedAt: daysAgo(19)},
+		{AssignmentID: 21, Score: ptr(30), GradedAt: daysAgo(19)},
+	}
+
+	// A 40 point assignment already graded as a zero, five days late
+	zero := []AssignmentGroup{{ID: 1, Assignments: []AssignmentInGroup{{ID: 30, PointsPossible: ptr(40), DueAt: daysAgo(5)}}}}
+	zeroSubs := []Submission{{AssignmentID: 30, Score: ptr(0), GradedAt: daysAgo(1), Missing: true}}
+
+	tests := []struct {
+		name        string
+		groups      []AssignmentGroup
+		subs        []Submission
+		weighted    bool
+		policy      *LatePolicy
+		id          int
+		wantGain    float64
+		wantPenalty float64
+	}{
+		// (50 + 100) / 200 = 75%, up from 50%
+		{"no policy", single, singleSubs, false, nil, 11, 25, 0},
+		// 3 days at 10% leaves 70 points: (50 + 70) / 200 = 60%
+		{"daily deduction", single, singleSubs, false, daily, 11, 10, 30},
+		// Work not yet past due keeps full credit
+		{"policy but not due yet", single, singleSubs, false, daily, 12, 25, 0},
+		// Category 2 goes from 60% to 80%: overall 70% to 80%
+		{"weighted, no policy", weighted, weightedSubs, true, nil, 22, 10, 0},
+		// 8 days would take 80%, but the floor stops it at 20%: 70 of 100
+		// in category 2, so 75% overall
+		{"weighted, floor", weighted, weightedSubs, true, floored, 22, 5, 20},
+		// Without the floor only 10 of 50 points are left: 40 of 100 in
+		// category 2, so 60% overall, lower than leaving it out
+		{"weighted, no floor", weighted, weightedSubs, true, daily, 22, -10, 80},
+		// A zero regraded at full credit less 50%: 20 of 40
+		{"graded zero", zero, zeroSubs, false, daily, 30, 50, 50},
+		{"graded zero, no policy", zero, zeroSubs, false, nil, 30, 100, 0},
+	}
+	for _, tt := range tests {
+		impacts := calculateAssignmentImpacts(tt.groups, tt.subs, 0, tt.weighted, nil, tt.policy, now)
+		impact := impacts[tt.id]
+		if impact == nil {
+			t.Errorf("%s: no impact for assignment %d", tt.name, tt.id)
+			continue
+		}
+		if math.Abs(impact.Gain-tt.wantGain) > 1e-9 {
+			t.Errorf("%s: gain = %g, want %g", tt.name, impact.Gain, tt.wantGain)
+		}
+		if math.Abs(impact.Penalty-tt.wantPenalty) > 1e-9 {
+			t.Errorf("%s: penalty = %g%%, want %g%%", tt.name, impact.Penalty, tt.wantPenalty)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// ABOUTME: Links to Canvas pages for assignments and courses.
+// ABOUTME: Builds URLs from the API's html_url or the base URL, and wraps text in OSC 8 terminal hyperlinks.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// webURL turns an html_url into an absolute URL; the planner API returns
+// paths relative to the Canvas site.
+func (c *CanvasClient) webURL(htmlURL string) string {
+	if htmlURL == "" || strings.Contains(htmlURL, "://") {
+		return htmlURL
+	}
+	return c.baseURL + "/" + strings.TrimPrefix(htmlURL, "/")
+}
+
+// courseURL links to a course's home page.
+func (c *CanvasClient) courseURL(courseID int) string {
+	return fmt.Sprintf("%s/courses/%d", c.baseURL, courseID)
+}
+
+// assignmentURL links to an assignment's page, preferring the html_url
+// Canvas returned for it.
+func (c *CanvasClient) assignmentURL(a Assignment, courseID int) string {
+	if a.HTMLURL != "" {
+		return c.webURL(a.HTMLURL)
+	}
+	return fmt.Sprintf("%s/assignments/%d", c.courseURL(courseID), a.ID)
+}
+
+// Terminals known to render OSC 8 hyperlinks, by TERM_PROGRAM.
+var hyperlinkTerminals = []string{"iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty", "Tabby", "rio"}
+
+// terminalHyperlinks reports whether the terminal is known to support OSC 8
+// links. FORCE_HYPERLINK=1 or 0 overrides the guess.
+var terminalHyperlinks = sync.OnceValue(func() bool {
+	if v, ok := os.LookupEnv("FORCE_HYPERLINK"); ok {
+		return v != "0" && v != ""
+	}
+	switch {
+	case os.Getenv("WT_SESSION") != "", os.Getenv("KITTY_WINDOW_ID") != "", os.Getenv("VTE_VERSION") != "":
+		return true
+	case containsString(hyperlinkTerminals, os.Getenv("TERM_PROGRAM")):
+		return true
+	}
+	term := os.Getenv("TERM")
+	return strings.Contains(term, "kitty") || strings.Contains(term, "alacritty") || strings.HasPrefix(term, "foot")
+})
+
+// hyperlink wraps text in an OSC 8 link when writing colored output to a
+// terminal that supports it, and returns it unchanged otherwise.
+func hyperlink(text, url string) string {
+	if url == "" || color.NoColor || !terminalHyperlinks() {
+		return text
+	}
+	// BEL rather than ESC \ ends each sequence; the table renderer's
+	// truncation only understands BEL inside OSC sequences
+	return "\x1b]8;;" + url + "\a" + text + "\x1b]8;;\a"
+}
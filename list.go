@@ -0,0 +1,329 @@
+// ABOUTME: Full assignment inventory for canvas-report.
+// ABOUTME: Lists every assignment with status, date, course and category filters and a choice of sort order.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+// Statuses accepted by list --status.
+const (
+	listMissing = "missing"
+	listLate    = "late"
+	listGraded  = "graded"
+	listPending = "pending"
+	listExcused = "excused"
+)
+
+var listStatuses = []string{listMissing, listLate, listGraded, listPending, listExcused}
+
+var listSorts = []string{"due", "impact", "points"}
+
+// Sections whose data list needs: the missing sections give assignments
+// their statuses.
+var assignmentSections = []string{sectionMissing, sectionUnconfirmed, sectionUpcoming, sectionWeekAhead}
+
+func listFlags(c *cli, fs *flag.FlagSet) {
+	fs.Var(&c.listStatus, "status", "only `status`: "+strings.Join(listStatuses, ", ")+" (repeatable or comma-separated)")
+	fs.StringVar(&c.listFrom, "from", "", "only work due on or after `date` (YYYY-MM-DD, today, or relative like -7d, +2w)")
+	fs.StringVar(&c.listTo, "to", "", "only work due on or before `date`")
+	fs.Var(&c.listCategory, "category", "only assignment groups matching `pattern` (repeatable)")
+	fs.StringVar(&c.listSort, "sort", "due", "sort by `field`: "+strings.Join(listSorts, ", "))
+	strictFlag(c, fs)
+}
+
+// listQuery is a parsed set of list filters.
+type listQuery struct {
+	statuses []string
+	from, to time.Time // Zero when open-ended
+	category Filter
+	sort     string
+}
+
+func (c *cli) listQuery() (listQuery, error) {
+	q := listQuery{category: Filter{Include: c.listCategory}, sort: c.listSort}
+	for _, s := range c.listStatus {
+		for _, status := range strings.Split(s, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if !containsString(listStatuses, status) {
+				return q, fmt.Errorf("unknown status %q (want %s)", status, strings.Join(listStatuses, ", "))
+			}
+			q.statuses = append(q.statuses, status)
+		}
+	}
+	if !containsString(listSorts, q.sort) {
+		return q, fmt.Errorf("unknown sort %q (want %s)", q.sort, strings.Join(listSorts, ", "))
+	}
+
+	now := time.Now()
+	var err error
+	if c.listFrom != "" {
+		if q.from, err = parseListDate(c.listFrom, now); err != nil {
+			return q, fmt.Errorf("--from: %w", err)
+		}
+	}
+	if c.listTo != "" {
+		if q.to, err = parseListDate(c.listTo, now); err != nil {
+			return q, fmt.Errorf("--to: %w", err)
+		}
+		q.to = q.to.AddDate(0, 0, 1).Add(-time.Nanosecond) // Through the end of that day
+	}
+	if !q.from.IsZero() && !q.to.IsZero() && q.to.Before(q.from) {
+		return q, errors.New("--to is before --from")
+	}
+	return q, nil
+}
+
+var relativeDate = regexp.MustCompile(`^([+-]?\d+)([dw])$`)
+
+// parseListDate reads a local date: YYYY-MM-DD, "today", or a number of
+// days or weeks from today such as -7d or +2w.
+func parseListDate(s string, now time.Time) (time.Time, error) {
+	today := truncateToDay(now)
+	if strings.EqualFold(s, "today") {
+		return today, nil
+	}
+	if m := relativeDate.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if m[2] == "w" {
+			n *= 7
+		}
+		return today.AddDate(0, 0, n), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q isn't a date (want YYYY-MM-DD, today, or like +14d)", s)
+	}
+	return t, nil
+}
+
+// listStatusesOf returns which list statuses an assignment has; late and
+// graded can go together with the others.
+func listStatusesOf(a EnrichedAssignment) []string {
+	sub := a.Submission
+	var statuses []string
+	switch {
+	case sub != nil && sub.Excused:
+		return []string{listExcused}
+	case a.Status != "":
+		statuses = append(statuses, listMissing)
+	case !isCompleted(sub):
+		statuses = append(statuses, listPending)
+	}
+	if submittedLate(sub) {
+		statuses = append(statuses, listLate)
+	}
+	if sub != nil && sub.GradedAt != nil {
+		statuses = append(statuses, listGraded)
+	}
+	return statuses
+}
+
+func (q listQuery) matches(a EnrichedAssignment) bool {
+	due := a.DueAt.Local()
+	if !q.from.IsZero() && due.Before(q.from) {
+		return false
+	}
+	if !q.to.IsZero() && due.After(q.to) {
+		return false
+	}
+	if len(q.category.Include) > 0 && !q.category.Allows(0, a.GroupName) {
+		return false
+	}
+	if len(q.statuses) == 0 {
+		return true
+	}
+	for _, s := range listStatusesOf(a) {
+		if containsString(q.statuses, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply filters and sorts one student's assignments.
+func (q listQuery) apply(assignments []EnrichedAssignment) []EnrichedAssignment {
+	var out []EnrichedAssignment
+	for _, a := range assignments {
+		if q.matches(a) {
+			out = append(out, a)
+		}
+	}
+	sortAssignments(out, q.sort)
+	return out
+}
+
+func runList(c *cli, args []string) error {
+	q, err := c.listQuery()
+	if err != nil {
+		return &exitCodeError{code: exitUsage, err: err}
+	}
+	// Old work needs its missing status too
+	c.showAll = true
+	reports, err := c.loadReports(args, assignmentSections)
+	if err != nil {
+		return err
+	}
+	var students []studentData
+	for _, r := range reports {
+		collected, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		students = append(students, collected...)
+	}
+	if len(students) == 0 {
+		fmt.Println(noStudentsMessage)
+		return nil
+	}
+
+	problems := 0
+	for i := range students {
+		students[i].assignments = q.apply(students[i].assignments)
+		problems += len(students[i].problems)
+	}
+
+	switch c.format {
+	case "json":
+		type studentList struct {
+			Name        string           `json:"name"`
+			Assignments []jsonAssignment `json:"assignments"`
+			Problems    []jsonProblem    `json:"problems,omitempty"`
+		}
+		out := []studentList{}
+		for _, data := range students {
+			sl := studentList{Name: data.name, Assignments: []jsonAssignment{}, Problems: problemsJSON(data.problems)}
+			for _, a := range data.assignments {
+				sl.Assignments = append(sl.Assignments, assignmentJSON(a))
+			}
+			out = append(out, sl)
+		}
+		if err := writeJSON(os.Stdout, out); err != nil {
+			return err
+		}
+	case "markdown":
+		for i, data := range students {
+			if i > 0 {
+				fmt.Println()
+			}
+			printListMarkdown(os.Stdout, data)
+		}
+	default:
+		for _, data := range students {
+			printList(os.Stdout, data)
+		}
+	}
+	return reports[0].checkStrict(problems)
+}
+
+// listStatusLabel is the status column: the missing label, or what the
+// submission shows, with any points the late policy took off.
+func listStatusLabel(a EnrichedAssignment) string {
+	label := exportStatus(a)
+	if deduction := formatDeduction(a.Submission); deduction != "" {
+		label += " (" + deduction + ")"
+	}
+	return label
+}
+
+func printList(w io.Writer, data studentData) {
+	printHeader(w, data.name)
+	if len(data.assignments) == 0 {
+		color.New(color.Faint).Fprintln(w, "  No assignments match.")
+	} else {
+		color.New(color.Bold).Fprintf(w, "ASSIGNMENTS (%d)\n", len(data.assignments))
+		printListTable(w, data.assignments)
+	}
+	if len(data.problems) > 0 {
+		fmt.Fprintln(w)
+		printProblems(w, data.problems)
+	}
+}
+
+func printListTable(w io.Writer, assignments []EnrichedAssignment) {
+	table := tablewriter.NewWriter(w)
+	table.Configure(func(cfg *tablewriter.Config) {
+		cfg.Row.Formatting.AutoWrap = tw.WrapTruncate
+		cfg.Row.Alignment.PerColumn = []tw.Align{
+			tw.AlignLeft,  // Due
+			tw.AlignLeft,  // Subject
+			tw.AlignLeft,  // Assignment
+			tw.AlignRight, // Pts
+			tw.AlignRight, // Score
+			tw.AlignRight, // Impact
+			tw.AlignLeft,  // Status
+		}
+	})
+	table.Header("Due", "Subject", "Assignment", "Pts", "Score", "Impact", "Status")
+
+	red := color.New(color.FgRed)
+	yellow := color.New(color.FgYellow)
+	dim := color.New(color.Faint)
+
+	for _, a := range assignments {
+		due := strings.ToLower(a.DueAt.Local().Format("Mon 1/2 3pm"))
+		subject := hyperlink(truncateString(a.CourseName, 22), a.CourseURL)
+		name := hyperlink(truncateString(a.Name, 40), a.URL)
+		pts, score := "", ""
+		if a.PointsPossible != nil {
+			pts = fmt.Sprintf("%d", int(*a.PointsPossible))
+		}
+		if a.Submission != nil && a.Submission.Score != nil {
+			score = strconv.FormatFloat(*a.Submission.Score, 'f', -1, 64)
+		}
+		impact := ""
+		if !isCompleted(a.Submission) {
+			impact = formatImpact(a.Impact)
+		}
+		status := listStatusLabel(a)
+
+		switch {
+		case a.Status == "Unconfirmed" || a.Status == "Marked missing":
+			status = yellow.Sprint(status)
+		case a.Status != "":
+			status = red.Sprint(status)
+		case isCompleted(a.Submission):
+			due, pts, score, status = dim.Sprint(due), dim.Sprint(pts), dim.Sprint(score), dim.Sprint(status)
+		}
+		table.Append(due, subject, name, pts, score, impact, status)
+	}
+	table.Render()
+}
+
+func printListMarkdown(w io.Writer, data studentData) {
+	fmt.Fprintf(w, "## %s\n\n", mdEscape(data.name))
+	if len(data.assignments) == 0 {
+		fmt.Fprintln(w, "No assignments match.")
+		return
+	}
+	fmt.Fprintln(w, "| Due | Subject | Assignment | Pts | Score | Impact | Status |")
+	fmt.Fprintln(w, "|---|---|---|--:|--:|--:|---|")
+	for _, a := range data.assignments {
+		pts, score, impact := "", "", ""
+		if a.PointsPossible != nil {
+			pts = fmt.Sprintf("%d", int(*a.PointsPossible))
+		}
+		if a.Submission != nil && a.Submission.Score != nil {
+			score = strconv.FormatFloat(*a.Submission.Score, 'f', -1, 64)
+		}
+		if !isCompleted(a.Submission) {
+			impact = formatImpact(a.Impact)
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s |\n",
+			strings.ToLower(a.DueAt.Local().Format("Mon 1/2 3pm")), mdLink(a.CourseName, a.CourseURL),
+			mdLink(a.Name, a.URL), pts, score, impact, mdEscape(listStatusLabel(a)))
+	}
+}
@@ -0,0 +1,203 @@
+// ABOUTME: Markdown rendering of the report for canvas-report.
+// ABOUTME: Writes GitHub-flavored tables per section, or short bullet lists for pasting into chat.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Status emoji replacing the colored glyphs of the terminal tables.
+var markdownStatus = map[string]string{
+	"✗": "❌",
+	"!": "⚠️",
+	"?": "❓",
+	"0": "0️⃣",
+	"✓": "✅",
+	"L": "⏰",
+}
+
+var markdownSectionEmoji = map[string]string{
+	sectionMissing:     "🔴",
+	sectionUnconfirmed: "❔",
+	sectionUpcoming:    "🟡",
+	sectionWeekAhead:   "🔵",
+}
+
+// markdownEscaper escapes characters that would otherwise start formatting
+// or end a table cell.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`",
+	"[", `\[`, "]", `\]`, "<", `\<`, "\n", " ",
+)
+
+func mdEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// mdLink is the escaped text linked to url, or just the text without one.
+func mdLink(text, url string) string {
+	if url == "" {
+		return mdEscape(text)
+	}
+	return "[" + mdEscape(text) + "](" + strings.ReplaceAll(url, ")", "%29") + ")"
+}
+
+// renderMarkdown writes the students' reports as Markdown, from the same
+// sections as the HTML report.
+func renderMarkdown(w io.Writer, students []studentData, compact bool) {
+	generated := time.Now().Local().Format("Mon Jan 2, 2006 at 3:04 PM")
+	for i, data := range students {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		hs := newHTMLStudent(data)
+		if compact {
+			printMarkdownCompact(w, hs)
+		} else {
+			printMarkdownStudent(w, hs, generated)
+		}
+	}
+}
+
+func printMarkdownStudent(w io.Writer, hs htmlStudent, generated string) {
+	fmt.Fprintf(w, "## %s\n\n", mdEscape(hs.Name))
+	fmt.Fprintf(w, "_Generated %s_\n", generated)
+
+	for _, sec := range hs.Sections {
+		emoji := markdownSectionEmoji[sec.Kind]
+		if sec.Kind == sectionMissing && len(sec.Rows) == 0 {
+			emoji = "🟢"
+		}
+		fmt.Fprintf(w, "\n### %s %s\n\n", emoji, sec.Title)
+		if len(sec.Rows) == 0 {
+			fmt.Fprintf(w, "%s\n", sec.Empty)
+			continue
+		}
+		fmt.Fprintln(w, "| Subject | Assignment | Due | Pts | Impact | |")
+		fmt.Fprintln(w, "|---|---|---|--:|--:|:-:|")
+		for _, row := range sec.Rows {
+			name := mdLink(row.Name, row.URL)
+			if row.Category != "" {
+				name += " _(" + mdEscape(row.Category) + ")_"
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n",
+				mdLink(row.Course, row.CourseURL), name, row.Due, row.Pts, row.Impact, markdownStatus[row.Status])
+		}
+	}
+
+	for _, pg := range hs.Grades {
+		fmt.Fprintf(w, "\n### 🟣 GRADES - %s\n\n", mdEscape(pg.Title))
+		fmt.Fprintln(w, "| Subject | % | Points | Possible | Weight |")
+		fmt.Fprintln(w, "|---|--:|--:|--:|--:|")
+		for _, row := range pg.Rows {
+			name := mdLink(row.Name, row.URL)
+			if row.Category {
+				name = "&nbsp;&nbsp;_" + name + "_"
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", name, row.Percent, row.Points, row.Possible, row.Weight)
+		}
+	}
+
+	if len(hs.Problems) > 0 {
+		fmt.Fprintf(w, "\n### ⚠️ DATA PROBLEMS (%d)\n\n", len(hs.Problems))
+		for _, p := range hs.Problems {
+			fmt.Fprintf(w, "- %s: %s. %s\n", mdEscape(p.Where), mdEscape(p.Kind), mdEscape(p.Detail))
+		}
+	}
+
+	fmt.Fprintf(w, "\n**%s**\n", mdEscape(hs.Summary))
+}
+
+// printMarkdownCompact writes one student as a status line and bullet
+// lists, which survive chat apps that don't render tables.
+func printMarkdownCompact(w io.Writer, hs htmlStudent) {
+	fmt.Fprintf(w, "**%s**: %s\n", mdEscape(hs.Name), mdEscape(hs.Summary))
+
+	for _, sec := range hs.Sections {
+		if len(sec.Rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s **%s**\n", markdownSectionEmoji[sec.Kind], sec.Title)
+		for _, row := range sec.Rows {
+			line := "- "
+			if status := markdownStatus[row.Status]; status != "" {
+				line += status + " "
+			}
+			line += mdLink(row.Name, row.URL) + " · " + mdEscape(row.Course) + " · " + row.Due
+			if row.Impact != "" && row.Impact != "-" {
+				line += " · " + row.Impact
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	for _, pg := range hs.Grades {
+		var grades []string
+		for _, row := range pg.Rows {
+			if !row.Category {
+				grades = append(grades, mdEscape(row.Name)+" "+row.Percent)
+			}
+		}
+		if len(grades) > 0 {
+			fmt.Fprintf(w, "\n🟣 **Grades**: %s\n", strings.Join(grades, ", "))
+		}
+	}
+}
+
+// printPlannerMarkdown writes one student's planner as Markdown tables.
+func printPlannerMarkdown(w io.Writer, data plannerData) {
+	fmt.Fprintf(w, "## %s\n", mdEscape(data.name))
+
+	pending := 0
+	for _, e := range data.dated {
+		if !e.Done {
+			pending++
+		}
+	}
+	fmt.Fprintf(w, "\n### 🟡 TO DO - NEXT 2 WEEKS (%d pending)\n\n", pending)
+	if len(data.dated) == 0 {
+		fmt.Fprintln(w, "Nothing in the planner.")
+	} else {
+		printPlannerMarkdownTable(w, data.dated)
+	}
+
+	if len(data.undated) > 0 {
+		fmt.Fprintf(w, "\n### 🔵 UNDATED (%d)\n\n", len(data.undated))
+		printPlannerMarkdownTable(w, data.undated)
+	}
+
+	if len(data.problems) > 0 {
+		fmt.Fprintf(w, "\n### ⚠️ DATA PROBLEMS (%d)\n\n", len(data.problems))
+		for _, p := range data.problems {
+			fmt.Fprintf(w, "- %s: %s. %s\n", mdEscape(p.where()), mdEscape(p.kind()), mdEscape(p.detail()))
+		}
+	}
+}
+
+func printPlannerMarkdownTable(w io.Writer, entries []plannerEntry) {
+	fmt.Fprintln(w, "| Due | Subject | Type | Item | Pts | |")
+	fmt.Fprintln(w, "|---|---|---|---|--:|:-:|")
+	for _, e := range entries {
+		due := "undated"
+		if e.Date != nil {
+			due = strings.ToLower(e.Date.Local().Format("Mon 1/2 3pm"))
+		}
+		pts := ""
+		if e.Points != nil {
+			pts = fmt.Sprintf("%d", int(*e.Points))
+		}
+		status := ""
+		switch {
+		case e.Done:
+			status = markdownStatus["✓"]
+		case e.Missing:
+			status = markdownStatus["✗"]
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s |\n",
+			due, mdEscape(e.CourseName), e.Type, mdLink(e.Title, e.URL), pts, status)
+	}
+}
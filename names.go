@@ -0,0 +1,92 @@
+// ABOUTME: Course display names for canvas-report.
+// ABOUTME: Applies configured aliases and Canvas course nicknames so long course names stay readable.
+
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// courseNamer resolves the name a course is displayed under. Configured
+// aliases win, then the user's Canvas nickname, then the course name itself.
+type courseNamer struct {
+	client    *CanvasClient
+	aliases   map[string]string // Course ID or name pattern -> display name
+	once      sync.Once
+	nicknames map[int]string
+	err       error // Why the nicknames couldn't be loaded, if they couldn't
+}
+
+func newCourseNamer(client *CanvasClient, aliases map[string]string) *courseNamer {
+	return &courseNamer{client: client, aliases: aliases}
+}
+
+func (n *courseNamer) loadNicknames() {
+	n.once.Do(func() {
+		n.nicknames = make(map[int]string)
+		nicknames, err := n.client.CourseNicknames()
+		if err != nil {
+			n.err = err
+			return
+		}
+		for _, nick := range nicknames {
+			if nick.Nickname != "" {
+				n.nicknames[nick.CourseID] = nick.Nickname
+			}
+		}
+	})
+}
+
+// addProblem records a failure to load nicknames against a student, so it
+// shows with their other data problems. Call it once the student's courses
+// have been named.
+func (n *courseNamer) addProblem(problems *problemLog) {
+	if n.err != nil {
+		problems.add("", atStage("course nicknames", n.err), "courses shown under their Canvas names")
+	}
+}
+
+func (n *courseNamer) name(course Course) string {
+	if alias, ok := n.alias(course); ok {
+		return alias
+	}
+
+	n.loadNicknames()
+	if nick, ok := n.nicknames[course.ID]; ok {
+		return nick
+	}
+
+	if course.Name != "" {
+		return course.Name
+	}
+	if course.OriginalName != "" {
+		return course.OriginalName
+	}
+	return "Unknown Course"
+}
+
+func (n *courseNamer) alias(course Course) (string, bool) {
+	if len(n.aliases) == 0 {
+		return "", false
+	}
+	if alias, ok := n.aliases[strconv.Itoa(course.ID)]; ok {
+		return alias, true
+	}
+
+	// Check patterns in a stable order so overlapping patterns behave predictably
+	patterns := make([]string, 0, len(n.aliases))
+	for p := range n.aliases {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+
+	names := []string{course.Name, course.OriginalName}
+	for _, p := range patterns {
+		if matchPattern(p, course.ID, names) {
+			return n.aliases[p], true
+		}
+	}
+	return "", false
+}
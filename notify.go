@@ -0,0 +1,574 @@
+// ABOUTME: Webhook notifications for canvas-report.
+// ABOUTME: Posts newly missing work, grade drops and summaries to JSON, Slack, Discord and ntfy endpoints.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notification event kinds.
+const (
+	eventMissing   = "missing"    // Work newly flagged missing
+	eventGradeDrop = "grade_drop" // A course grade fell since the last run
+	eventSummary   = "summary"    // The --check line, sent with notify --summary
+)
+
+var eventKinds = []string{eventMissing, eventGradeDrop, eventSummary}
+
+// Webhook payload shapes.
+var webhookFormats = []string{"json", "slack", "discord", "ntfy"}
+
+const (
+	defaultAttempts  = 3
+	defaultGradeDrop = 1.0 // Percentage points
+	maxRetryAfter    = time.Minute
+)
+
+// notifyBackoff is the wait before the first retry; it doubles each attempt.
+var notifyBackoff = time.Second
+
+// NotifyConfig is the notify section of the config file.
+type NotifyConfig struct {
+	Webhooks  []Webhook `yaml:"webhooks,omitempty"`
+	StateFile string    `yaml:"state_file,omitempty"` // Default: notify-state.json beside the config file
+	GradeDrop float64   `yaml:"grade_drop,omitempty"` // Percentage points a grade must fall to notify
+}
+
+// Webhook is one notification destination.
+type Webhook struct {
+	Name     string            `yaml:"name"`
+	URL      string            `yaml:"url"`
+	Format   string            `yaml:"format,omitempty"`   // json (default), slack, discord or ntfy
+	Events   []string          `yaml:"events,omitempty"`   // Event kinds to send; empty means all
+	Students Filter            `yaml:"students,omitempty"` // Whose events to send; empty means everyone
+	Template string            `yaml:"template,omitempty"` // text/template for the message, given the event
+	Headers  map[string]string `yaml:"headers,omitempty"`  // Extra request headers, e.g. Authorization
+	Attempts int               `yaml:"attempts,omitempty"` // Tries before giving up; default 3
+
+	tmpl *template.Template
+}
+
+// notifyEvent is one notification. It is the payload of json webhooks and
+// the data for message templates.
+type notifyEvent struct {
+	Kind        string           `json:"kind"`
+	Student     string           `json:"student"`
+	Title       string           `json:"title"`
+	Message     string           `json:"message"`
+	Assignments []jsonAssignment `json:"assignments,omitempty"` // missing
+	Course      string           `json:"course,omitempty"`      // grade_drop
+	Grade       float64          `json:"grade,omitempty"`       // grade_drop
+	Previous    float64          `json:"previous,omitempty"`    // grade_drop
+	URL         string           `json:"url,omitempty"`         // The course, or the only missing assignment
+	Status      *checkStatus     `json:"status,omitempty"`      // summary
+}
+
+// studentSnapshot is what a webhook was last told about a student.
+type studentSnapshot struct {
+	Missing []string           `json:"missing"`           // "courseID/assignmentID"
+	Grades  map[string]float64 `json:"grades"`            // Percent keyed by "period/course"
+	Pending []notifyEvent      `json:"pending,omitempty"` // Events that couldn't be delivered, to retry
+}
+
+// notifyState is the state file: webhook name -> student name -> snapshot.
+type notifyState map[string]map[string]*studentSnapshot
+
+func notifyFlags(c *cli, fs *flag.FlagSet) {
+	fs.BoolVar(&c.dryRun, "dry-run", false, "print the notifications instead of sending them")
+	fs.BoolVar(&c.summary, "summary", false, "also send each student's one-line status")
+	fs.StringVar(&c.webhook, "webhook", "", "only notify the webhook with this `name`")
+}
+
+func runNotify(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+	webhooks, err := cfg.Notify.webhooks(c.webhook)
+	if err != nil {
+		return err
+	}
+	statePath, err := c.notifyStatePath(cfg)
+	if err != nil {
+		return err
+	}
+	state, err := loadNotifyState(statePath)
+	if err != nil {
+		return err
+	}
+
+	reports, err := c.newReports(cfg, allSections)
+	if err != nil {
+		return err
+	}
+	var students []studentData
+	for _, r := range reports {
+		r.quiet = true
+		collected, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		students = append(students, collected...)
+	}
+
+	gradeDrop := cfg.Notify.GradeDrop
+	if gradeDrop == 0 {
+		gradeDrop = defaultGradeDrop
+	}
+	httpClient := &http.Client{Timeout: 15 * time.Second}
+
+	failed := 0
+	for _, w := range webhooks {
+		if state[w.Name] == nil {
+			state[w.Name] = make(map[string]*studentSnapshot)
+		}
+		sent := 0
+		for _, data := range students {
+			if !w.Students.Allows(data.id, data.name) {
+				continue
+			}
+			prev := state[w.Name][data.name]
+			snap := newSnapshot(data, prev)
+
+			var events []notifyEvent
+			switch {
+			case prev == nil && c.dryRun:
+				fmt.Printf("%s: first run for %s; a real run records current work without notifying\n", w.Name, data.name)
+			case prev == nil:
+				fmt.Printf("%s: first run for %s; recorded current work without notifying\n", w.Name, data.name)
+			default:
+				// What failed last time goes out before anything new
+				events = slices.Concat(prev.Pending, changeEvents(data, prev, snap, gradeDrop))
+			}
+			if c.summary {
+				events = append(events, summaryEvent(data))
+			}
+
+			for _, ev := range events {
+				if !w.wants(ev.Kind) {
+					continue
+				}
+				if err := w.notify(httpClient, ev, c.dryRun); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s: %v\n", w.Name, ev.Title, err)
+					failed++
+					// A summary is stale by the next run, which sends its own
+					if ev.Kind != eventSummary {
+						snap.Pending = append(snap.Pending, ev)
+					}
+					continue
+				}
+				sent++
+			}
+			state[w.Name][data.name] = snap
+		}
+		if !c.dryRun {
+			fmt.Printf("%s: %d notification(s) sent\n", w.Name, sent)
+		}
+	}
+
+	if !c.dryRun {
+		if err := saveNotifyState(statePath, state); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d notification(s) could not be delivered", failed)
+	}
+	return nil
+}
+
+// webhooks validates the configured webhooks and returns those selected by
+// name (all of them when name is empty).
+func (n NotifyConfig) webhooks(name string) ([]*Webhook, error) {
+	if len(n.Webhooks) == 0 {
+		return nil, errors.New("no webhooks configured; add a notify.webhooks section to the config file")
+	}
+	var selected []*Webhook
+	seen := make(map[string]bool)
+	for i := range n.Webhooks {
+		w := &n.Webhooks[i]
+		if err := w.prepare(); err != nil {
+			return nil, err
+		}
+		if seen[w.Name] {
+			return nil, fmt.Errorf("two webhooks are named %q", w.Name)
+		}
+		seen[w.Name] = true
+		if name == "" || name == w.Name {
+			selected = append(selected, w)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no webhook named %q", name)
+	}
+	return selected, nil
+}
+
+// prepare checks the webhook's settings and parses its template.
+func (w *Webhook) prepare() error {
+	if w.Name == "" {
+		return fmt.Errorf("webhook %q needs a name", w.URL)
+	}
+	if w.URL == "" {
+		return fmt.Errorf("webhook %q needs a url", w.Name)
+	}
+	if w.Format == "" {
+		w.Format = "json"
+	}
+	if !slices.Contains(webhookFormats, w.Format) {
+		return fmt.Errorf("webhook %q: unknown format %q (want %s)", w.Name, w.Format, strings.Join(webhookFormats, ", "))
+	}
+	for _, kind := range w.Events {
+		if !slices.Contains(eventKinds, kind) {
+			return fmt.Errorf("webhook %q: unknown event %q (want %s)", w.Name, kind, strings.Join(eventKinds, ", "))
+		}
+	}
+	if w.Template != "" {
+		tmpl, err := template.New(w.Name).Parse(w.Template)
+		if err != nil {
+			return fmt.Errorf("webhook %q: %w", w.Name, err)
+		}
+		w.tmpl = tmpl
+	}
+	return nil
+}
+
+func (w *Webhook) wants(kind string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, kind)
+}
+
+// notifyStatePath returns where notify remembers what each webhook was told.
+func (c *cli) notifyStatePath(cfg *Config) (string, error) {
+	if cfg.Notify.StateFile != "" {
+		return cfg.Notify.StateFile, nil
+	}
+	path, err := c.resolvedConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "notify-state.json"), nil
+}
+
+func loadNotifyState(path string) (notifyState, error) {
+	state := make(notifyState)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func saveNotifyState(path string, state notifyState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+func missingKey(a EnrichedAssignment) string {
+	return fmt.Sprintf("%d/%d", a.CourseID, a.ID)
+}
+
+func gradeKey(pg periodGrades, g CourseGrade) string {
+	return pg.period.Title + "/" + g.CourseName
+}
+
+// newSnapshot records a student's missing work and grades. When some data
+// couldn't be fetched, what's absent may just be unknown, so the previous
+// snapshot's entries are kept rather than treated as resolved.
+func newSnapshot(data studentData, prev *studentSnapshot) *studentSnapshot {
+	snap := &studentSnapshot{Grades: make(map[string]float64)}
+	if sec := data.section(sectionMissing); sec != nil {
+		for _, a := range sec.assignments {
+			snap.Missing = append(snap.Missing, missingKey(a))
+		}
+	}
+	for _, pg := range data.grades {
+		for _, g := range pg.grades {
+			snap.Grades[gradeKey(pg, g)] = g.Percent
+		}
+	}
+
+	if prev != nil && len(data.problems) > 0 {
+		for _, key := range prev.Missing {
+			if !slices.Contains(snap.Missing, key) {
+				snap.Missing = append(snap.Missing, key)
+			}
+		}
+		for key, percent := range prev.Grades {
+			if _, ok := snap.Grades[key]; !ok {
+				snap.Grades[key] = percent
+			}
+		}
+	}
+	slices.Sort(snap.Missing)
+	return snap
+}
+
+// changeEvents compares a student's data with what was last sent.
+func changeEvents(data studentData, prev, snap *studentSnapshot, gradeDrop float64) []notifyEvent {
+	var events []notifyEvent
+
+	var added []EnrichedAssignment
+	if sec := data.section(sectionMissing); sec != nil {
+		for _, a := range sec.assignments {
+			if !slices.Contains(prev.Missing, missingKey(a)) {
+				added = append(added, a)
+			}
+		}
+	}
+	if len(added) > 0 {
+		ev := notifyEvent{
+			Kind:    eventMissing,
+			Student: data.name,
+			Title:   fmt.Sprintf("%s: %d newly missing", data.name, len(added)),
+		}
+		var lines []string
+		for _, a := range added {
+			ev.Assignments = append(ev.Assignments, assignmentJSON(a))
+			lines = append(lines, fmt.Sprintf("%s: %s (due %s)", a.CourseName, a.Name, a.DueAt.Local().Format("Mon Jan 2")))
+		}
+		ev.Message = strings.Join(lines, "\n")
+		if len(added) == 1 {
+			ev.URL = added[0].URL
+		}
+		events = append(events, ev)
+	}
+
+	for _, pg := range data.grades {
+		for _, g := range pg.grades {
+			before, ok := prev.Grades[gradeKey(pg, g)]
+			if !ok || before-g.Percent < gradeDrop {
+				continue
+			}
+			events = append(events, notifyEvent{
+				Kind:     eventGradeDrop,
+				Student:  data.name,
+				Title:    fmt.Sprintf("%s: %s grade dropped", data.name, g.CourseName),
+				Message:  fmt.Sprintf("%s is now %.1f%%, down from %.1f%%", g.CourseName, g.Percent, before),
+				Course:   g.CourseName,
+				Grade:    g.Percent,
+				Previous: before,
+				URL:      g.URL,
+			})
+		}
+	}
+	return events
+}
+
+func summaryEvent(data studentData) notifyEvent {
+	st := newCheckStatus(data)
+	return notifyEvent{
+		Kind:    eventSummary,
+		Student: data.name,
+		Title:   data.name,
+		Message: strings.TrimPrefix(st.line(true), data.name+": "),
+		Status:  &st,
+	}
+}
+
+// notifyRequest is a rendered webhook call.
+type notifyRequest struct {
+	body        []byte
+	contentType string
+	headers     map[string]string
+}
+
+// notify renders ev for the webhook and sends it, or prints it on a dry run.
+func (w *Webhook) notify(client *http.Client, ev notifyEvent, dryRun bool) error {
+	if w.tmpl != nil {
+		var buf strings.Builder
+		if err := w.tmpl.Execute(&buf, ev); err != nil {
+			return fmt.Errorf("template: %w", err)
+		}
+		ev.Message = buf.String()
+	}
+	req, err := w.render(ev)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("%s (%s) -> %s\n", w.Name, w.Format, w.URL)
+		for _, name := range sortedKeys(req.headers) {
+			value := req.headers[name]
+			// Configured headers usually carry tokens
+			if _, ok := w.Headers[name]; ok {
+				value = maskToken(value)
+			}
+			fmt.Printf("  %s: %s\n", name, value)
+		}
+		fmt.Printf("  %s\n\n", strings.ReplaceAll(string(req.body), "\n", "\n  "))
+		return nil
+	}
+	return w.deliver(client, req)
+}
+
+// render builds the request body for the webhook's format.
+func (w *Webhook) render(ev notifyEvent) (notifyRequest, error) {
+	req := notifyRequest{contentType: "application/json", headers: make(map[string]string)}
+	var payload any
+	switch w.Format {
+	case "json":
+		payload = ev
+	case "slack":
+		payload = map[string]any{
+			"text": ev.Title + "\n" + ev.Message,
+			"blocks": []map[string]any{
+				{"type": "header", "text": map[string]string{"type": "plain_text", "text": ev.Title}},
+				{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": ev.Message}},
+			},
+		}
+	case "discord":
+		payload = map[string]any{
+			"username": "canvas-report",
+			"embeds": []map[string]any{
+				{"title": ev.Title, "description": ev.Message, "color": discordColors[ev.Kind]},
+			},
+		}
+	case "ntfy":
+		// ntfy takes the message as the body and everything else as headers
+		req.body = []byte(ev.Message)
+		req.contentType = "text/plain; charset=utf-8"
+		req.headers["Title"] = ev.Title
+		req.headers["Tags"] = ntfyTags[ev.Kind]
+		if ev.Kind == eventMissing {
+			req.headers["Priority"] = "high"
+		}
+		if ev.URL != "" {
+			req.headers["Click"] = ev.URL
+		}
+	}
+	if payload != nil {
+		body, err := json.MarshalIndent(payload, "", "  ")
+		if err != nil {
+			return req, err
+		}
+		req.body = body
+	}
+	for name, value := range w.Headers {
+		req.headers[name] = value
+	}
+	return req, nil
+}
+
+var discordColors = map[string]int{
+	eventMissing:   0xE74C3C,
+	eventGradeDrop: 0xE67E22,
+	eventSummary:   0x3498DB,
+}
+
+var ntfyTags = map[string]string{
+	eventMissing:   "warning",
+	eventGradeDrop: "chart_with_downwards_trend",
+	eventSummary:   "books",
+}
+
+// deliver posts the request, retrying network errors, 429s and 5xx
+// responses with exponential backoff (or the server's Retry-After, unless
+// it asks for longer than maxRetryAfter).
+func (w *Webhook) deliver(client *http.Client, req notifyRequest) error {
+	attempts := w.Attempts
+	if attempts <= 0 {
+		attempts = defaultAttempts
+	}
+	backoff := notifyBackoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, retry, err := w.post(client, req)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= attempts {
+			if attempt > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return err
+		}
+		if retryAfter > maxRetryAfter {
+			return fmt.Errorf("%w (asked to retry after %s)", err, retryAfter)
+		}
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// post sends one attempt. It reports whether a failure is worth retrying
+// and how long the server asked us to wait.
+func (w *Webhook) post(client *http.Client, req notifyRequest) (time.Duration, bool, error) {
+	httpReq, err := http.NewRequest("POST", w.URL, bytes.NewReader(req.body))
+	if err != nil {
+		return 0, false, err
+	}
+	httpReq.Header.Set("Content-Type", req.contentType)
+	httpReq.Header.Set("User-Agent", "canvas-report/"+version)
+	for name, value := range req.headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 300 {
+		return 0, false, nil
+	}
+
+	err = fmt.Errorf("webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), retry, err
+}
+
+// parseRetryAfter reads a Retry-After header, given either as seconds or as
+// an HTTP date. It returns zero when there's no usable wait.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
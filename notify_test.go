@@ -0,0 +1,230 @@
+// ABOUTME: Tests for canvas-report webhook notifications.
+// ABOUTME: Covers snapshots and the events diffed from them, Retry-After parsing, and delivery retries.
+
+package main
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// notifyStudent builds a student with the given missing work and grades,
+// keyed as in the snapshot: "courseID/assignmentID" and "period/course".
+func notifyStudent(missing []string, grades map[string]float64) studentData {
+	data := studentData{name: "Jane Doe"}
+	sec := reportSection{kind: sectionMissing}
+	for _, key := range missing {
+		var a EnrichedAssignment
+		course, id, _ := strings.Cut(key, "/")
+		a.CourseID, _ = strconv.Atoi(course)
+		a.ID, _ = strconv.Atoi(id)
+		a.CourseName = "Course " + course
+		a.Name = "Assignment " + id
+		sec.assignments = append(sec.assignments, a)
+	}
+	data.sections = []reportSection{sec}
+	for _, key := range slices.Sorted(maps.Keys(grades)) {
+		period, course, _ := strings.Cut(key, "/")
+		data.grades = append(data.grades, periodGrades{
+			period: GradingPeriod{Title: period},
+			grades: []CourseGrade{{CourseName: course, Percent: grades[key]}},
+		})
+	}
+	return data
+}
+
+func TestNewSnapshot(t *testing.T) {
+	prev := &studentSnapshot{
+		Missing: []string{"1/10", "2/20"},
+		Grades:  map[string]float64{"Q1/Math": 90, "Q1/Art": 85},
+	}
+	unavailable := []dataProblem{{Course: "Art", Stage: "submissions", Err: errors.New("403")}}
+
+	tests := []struct {
+		name        string
+		data        studentData
+		prev        *studentSnapshot
+		wantMissing []string
+		wantGrades  map[string]float64
+	}{
+		{
+			"first run",
+			notifyStudent([]string{"3/30", "1/10"}, map[string]float64{"Q1/Math": 88}),
+			nil,
+			[]string{"1/10", "3/30"},
+			map[string]float64{"Q1/Math": 88},
+		},
+		{
+			"first run with problems has nothing to keep",
+			func() studentData {
+				d := notifyStudent(nil, map[string]float64{"Q1/Math": 88})
+				d.problems = unavailable
+				return d
+			}(),
+			nil,
+			nil,
+			map[string]float64{"Q1/Math": 88},
+		},
+		{
+			"resolved work and dropped courses are forgotten",
+			notifyStudent([]string{"1/10"}, map[string]float64{"Q1/Math": 80}),
+			prev,
+			[]string{"1/10"},
+			map[string]float64{"Q1/Math": 80},
+		},
+		{
+			"problems keep what might just be unknown",
+			func() studentData {
+				d := notifyStudent([]string{"3/30"}, map[string]float64{"Q1/Math": 80})
+				d.problems = unavailable
+				return d
+			}(),
+			prev,
+			[]string{"1/10", "2/20", "3/30"},
+			map[string]float64{"Q1/Math": 80, "Q1/Art": 85},
+		},
+	}
+	for _, tt := range tests {
+		snap := newSnapshot(tt.data, tt.prev)
+		if !slices.Equal(snap.Missing, tt.wantMissing) {
+			t.Errorf("%s: missing = %q, want %q", tt.name, snap.Missing, tt.wantMissing)
+		}
+		if len(snap.Grades) != len(tt.wantGrades) {
+			t.Errorf("%s: grades = %v, want %v", tt.name, snap.Grades, tt.wantGrades)
+		}
+		for key, want := range tt.wantGrades {
+			if got, ok := snap.Grades[key]; !ok || got != want {
+				t.Errorf("%s: grade %s = %v, want %v", tt.name, key, got, want)
+			}
+		}
+	}
+}
+
+func TestChangeEvents(t *testing.T) {
+	prev := &studentSnapshot{
+		Missing: []string{"1/10"},
+		Grades:  map[string]float64{"Q1/Math": 90, "Q1/Art": 85},
+	}
+
+	tests := []struct {
+		name        string
+		data        studentData
+		want        []string // Event kinds, in order
+		wantMissing int      // Assignments in the missing event
+	}{
+		{"nothing changed", notifyStudent([]string{"1/10"}, map[string]float64{"Q1/Math": 90, "Q1/Art": 85}), nil, 0},
+		{"work resolved", notifyStudent(nil, map[string]float64{"Q1/Math": 90}), nil, 0},
+		{"newly missing", notifyStudent([]string{"1/10", "2/20", "3/30"}, nil), []string{eventMissing}, 2},
+		{"drop below the threshold", notifyStudent(nil, map[string]float64{"Q1/Math": 89.5}), nil, 0},
+		{"drop at the threshold", notifyStudent(nil, map[string]float64{"Q1/Math": 89}), []string{eventGradeDrop}, 0},
+		{"grade rose", notifyStudent(nil, map[string]float64{"Q1/Math": 95}), nil, 0},
+		{"new course", notifyStudent(nil, map[string]float64{"Q2/Math": 50}), nil, 0},
+		{
+			"missing and two drops",
+			notifyStudent([]string{"2/20"}, map[string]float64{"Q1/Math": 70, "Q1/Art": 60}),
+			[]string{eventMissing, eventGradeDrop, eventGradeDrop}, 1,
+		},
+	}
+	for _, tt := range tests {
+		snap := newSnapshot(tt.data, prev)
+		events := changeEvents(tt.data, prev, snap, defaultGradeDrop)
+		var kinds []string
+		for _, ev := range events {
+			kinds = append(kinds, ev.Kind)
+			if ev.Kind == eventMissing && len(ev.Assignments) != tt.wantMissing {
+				t.Errorf("%s: missing event lists %d assignments, want %d", tt.name, len(ev.Assignments), tt.wantMissing)
+			}
+			if ev.Student != "Jane Doe" {
+				t.Errorf("%s: event for %q, want Jane Doe", tt.name, ev.Student)
+			}
+		}
+		if !slices.Equal(kinds, tt.want) {
+			t.Errorf("%s: events = %q, want %q", tt.name, kinds, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{" 10 ", 10 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{"Wed, 14 Oct 2026 12:00:30 GMT", 30 * time.Second},
+		{"Wed, 14 Oct 2026 11:59:00 GMT", 0}, // Already passed
+		{"3600", time.Hour},                  // Parsed as given; deliver decides it's too long
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	defer func(backoff time.Duration) { notifyBackoff = backoff }(notifyBackoff)
+	notifyBackoff = time.Millisecond
+
+	type reply struct {
+		status     int
+		retryAfter string
+	}
+	tests := []struct {
+		name      string
+		replies   []reply // The last one repeats
+		attempts  int
+		wantCalls int32
+		wantErr   string
+	}{
+		{"delivered first time", []reply{{http.StatusNoContent, ""}}, 0, 1, ""},
+		{"server error, then delivered", []reply{{500, ""}, {200, ""}}, 0, 2, ""},
+		{"rate limited, then delivered", []reply{{429, "0"}, {200, ""}}, 0, 2, ""},
+		{"client errors aren't retried", []reply{{400, ""}}, 0, 1, "webhook returned 400"},
+		{"gives up after the default attempts", []reply{{503, ""}}, 0, defaultAttempts, "after 3 attempts"},
+		{"configured attempts", []reply{{502, ""}}, 5, 5, "after 5 attempts"},
+		{"too long a Retry-After fails", []reply{{429, "120"}}, 0, 1, "asked to retry after 2m0s"},
+	}
+	for _, tt := range tests {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(calls.Add(1))
+			rep := tt.replies[min(n, len(tt.replies))-1]
+			if r.Header.Get("Content-Type") != "application/json" {
+				http.Error(w, "wrong content type", http.StatusUnsupportedMediaType)
+				return
+			}
+			if rep.retryAfter != "" {
+				w.Header().Set("Retry-After", rep.retryAfter)
+			}
+			w.WriteHeader(rep.status)
+		}))
+
+		w := &Webhook{Name: "test", URL: server.URL, Attempts: tt.attempts}
+		err := w.deliver(server.Client(), notifyRequest{body: []byte(`{}`), contentType: "application/json"})
+		server.Close()
+
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Errorf("%s: %d attempts, want %d", tt.name, got, tt.wantCalls)
+		}
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want it to mention %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// ABOUTME: Canvas OAuth2 login for canvas-report.
+// ABOUTME: Runs the authorization-code flow through a localhost redirect and refreshes expired access tokens.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLoginPort = 8976
+	loginTimeout     = 5 * time.Minute
+)
+
+// tokenResponse is Canvas's reply from /login/oauth2/token.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         struct {
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+func loginFlags(c *cli, fs *flag.FlagSet) {
+	fs.IntVar(&c.loginPort, "port", defaultLoginPort, "localhost port for the OAuth redirect (must match the developer key's redirect URI)")
+}
+
+// runLogin signs in through Canvas's OAuth2 authorization-code flow and
+// saves the resulting tokens to the selected profile.
+func runLogin(c *cli, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	path, err := c.resolvedConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if os.IsNotExist(err) {
+		cfg, err = &Config{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	name := c.profile
+	if name == "" {
+		name = defaultProfile
+	}
+	if name == allProfiles {
+		return fmt.Errorf("choose a single profile to log in to")
+	}
+	p, err := cfg.GetProfile(name)
+	if err != nil {
+		p = &Profile{}
+		if cfg.Profiles == nil {
+			cfg.Profiles = make(map[string]*Profile)
+		}
+		cfg.Profiles[name] = p
+	}
+
+	if baseURL := flagOrEnv(c.baseURL, envBaseURL); baseURL != "" {
+		p.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{"base_url", &p.BaseURL},
+		{"client_id", &p.ClientID},
+		{"client_secret", &p.ClientSecret},
+	} {
+		if *field.value != "" {
+			continue
+		}
+		value, err := promptValue(field.key)
+		if err != nil {
+			return err
+		}
+		*field.value = strings.TrimSuffix(strings.TrimSpace(value), "/")
+		if *field.value == "" {
+			return fmt.Errorf("%s is required", field.key)
+		}
+	}
+
+	token, err := authorize(p, c.loginPort)
+	if err != nil {
+		return err
+	}
+
+	p.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		p.RefreshToken = token.RefreshToken
+	}
+	if err := saveConfig(path, cfg); err != nil {
+		return err
+	}
+
+	who := token.User.Name
+	if who == "" {
+		who = "Canvas"
+	}
+	fmt.Printf("Logged in to %s as %s; saved to profile %q in %s\n", p.BaseURL, who, name, path)
+	return nil
+}
+
+// authorize sends the user to Canvas to approve access and waits for the
+// redirect back to a local listener, then exchanges the code for tokens.
+func authorize(p *Profile, port int) (*tokenResponse, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("listening for the OAuth redirect: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://localhost:%d/callback", port)
+
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("state") != state:
+			res.err = errors.New("OAuth redirect had the wrong state; try logging in again")
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("Canvas declined the login: %s", q.Get("error"))
+		case q.Get("code") == "":
+			res.err = errors.New("OAuth redirect had no authorization code")
+		default:
+			res.code = q.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "canvas-report is logged in. You can close this window.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	authURL := p.BaseURL + "/login/oauth2/auth?" + url.Values{
+		"client_id":     {p.ClientID},
+		"response_type": {"code"},
+		"redirect_uri":  {redirectURI},
+		"state":         {state},
+	}.Encode()
+
+	fmt.Println("Opening Canvas in your browser to approve access. If it doesn't open, visit:")
+	fmt.Println()
+	fmt.Println("  " + authURL)
+	fmt.Println()
+	if err := openBrowser(authURL); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not open a browser: %v\n", err)
+	}
+
+	select {
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return requestToken(p, url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {res.code},
+			"redirect_uri": {redirectURI},
+		})
+	case <-time.After(loginTimeout):
+		return nil, errors.New("timed out waiting for the Canvas login")
+	}
+}
+
+// requestToken posts a grant to Canvas's token endpoint using the profile's
+// developer key.
+func requestToken(p *Profile, form url.Values) (*tokenResponse, error) {
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.PostForm(p.BaseURL+"/login/oauth2/token", form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Canvas token error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("Canvas returned no access token")
+	}
+	return &token, nil
+}
+
+// refreshAccessToken trades the profile's refresh token for a new access
+// token, and a new refresh token if Canvas rotates it.
+func refreshAccessToken(p *Profile) (*tokenResponse, error) {
+	token, err := requestToken(p, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {p.RefreshToken},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("refreshing access token (run 'canvas-report login' again if this persists): %w", err)
+	}
+	return token, nil
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// openBrowser opens url in the user's default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,363 @@
+// ABOUTME: Prioritized catch-up plan for canvas-report.
+// ABOUTME: Ranks missing and upcoming work by grade gain, points, urgency and late penalty, with the recovery each course offers.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+func planFlags(c *cli, fs *flag.FlagSet) {
+	fs.IntVar(&c.planDays, "days", 14, "include work due within `n` days")
+	allFlag(c, fs)
+	strictFlag(c, fs)
+}
+
+// planItem is one ranked assignment.
+type planItem struct {
+	assignment EnrichedAssignment
+	score      float64
+	reason     string
+}
+
+// courseRecovery is what finishing a course's planned work could add to
+// its grade.
+type courseRecovery struct {
+	courseID int
+	course   string
+	url      string
+	count    int
+	current  *float64 // Current grade, when it could be fetched
+	gain     float64  // Sum of the items' gains, capped at full marks; an estimate, since gains don't add exactly
+}
+
+type studentPlan struct {
+	name     string
+	items    []planItem
+	courses  []courseRecovery
+	problems []dataProblem
+}
+
+// actionable reports whether there's still something to do for an
+// assignment: missing work, or work not yet turned in.
+func actionable(a EnrichedAssignment) bool {
+	if a.Submission != nil && a.Submission.Excused {
+		return false
+	}
+	return a.Status != "" || !isCompleted(a.Submission)
+}
+
+// planScore ranks an assignment. The grade it could add counts most, with
+// points breaking ties between assignments without an impact. Overdue work
+// and work due soon count for more, and overdue work in a course with a
+// late policy more still, since every day waiting costs points.
+func planScore(a EnrichedAssignment, now time.Time) (float64, string) {
+	gain, penalty := 0.0, 0.0
+	if a.Impact != nil {
+		gain, penalty = math.Max(a.Impact.Gain, 0), a.Impact.Penalty
+	}
+	value := gain + max(pointsOrNone(a), 0)/100
+
+	days := a.DueAt.Sub(now).Hours() / 24
+	var urgency float64
+	var reason string
+	switch {
+	case days < 0:
+		urgency = 2
+		reason = fmt.Sprintf("%s overdue", formatDays(-days))
+		if penalty > 0 {
+			urgency = 2.5
+			reason += fmt.Sprintf(", %g%% late penalty", penalty)
+		}
+	case days < 1:
+		urgency = 1.5
+		reason = "due within a day"
+	default:
+		urgency = 1 + 0.5/days
+		reason = "due in " + formatDays(days)
+	}
+	return value * urgency, reason
+}
+
+// formatDays rounds a number of days for the reason column.
+func formatDays(days float64) string {
+	if days < 1 {
+		return "<1 day"
+	}
+	n := int(math.Round(days))
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
+
+// buildPlan ranks a student's actionable work due before the horizon.
+func buildPlan(data studentData, horizon time.Duration, now time.Time) studentPlan {
+	plan := studentPlan{name: data.name, problems: data.problems}
+	byCourse := make(map[int]*courseRecovery)
+	for _, a := range data.assignments {
+		if !actionable(a) || a.DueAt.After(now.Add(horizon)) {
+			continue
+		}
+		// Past due work only belongs in the plan when it was listed as
+		// missing or unconfirmed
+		if a.Status == "" && a.DueAt.Before(now) {
+			continue
+		}
+		score, reason := planScore(a, now)
+		plan.items = append(plan.items, planItem{assignment: a, score: score, reason: reason})
+
+		cr := byCourse[a.CourseID]
+		if cr == nil {
+			cr = &courseRecovery{courseID: a.CourseID, course: a.CourseName, url: a.CourseURL}
+			byCourse[a.CourseID] = cr
+		}
+		cr.count++
+		if a.Impact != nil {
+			cr.gain += math.Max(a.Impact.Gain, 0)
+		}
+	}
+	for _, pg := range data.grades {
+		for _, g := range pg.grades {
+			if cr := byCourse[g.CourseID]; cr != nil && cr.current == nil {
+				percent := g.Percent
+				cr.current = &percent
+				cr.gain = math.Min(cr.gain, math.Max(100-percent, 0))
+			}
+		}
+	}
+
+	sort.SliceStable(plan.items, func(i, j int) bool {
+		if plan.items[i].score != plan.items[j].score {
+			return plan.items[i].score > plan.items[j].score
+		}
+		return plan.items[i].assignment.DueAt.Before(plan.items[j].assignment.DueAt)
+	})
+	for _, cr := range byCourse {
+		plan.courses = append(plan.courses, *cr)
+	}
+	sort.Slice(plan.courses, func(i, j int) bool {
+		if plan.courses[i].gain != plan.courses[j].gain {
+			return plan.courses[i].gain > plan.courses[j].gain
+		}
+		return plan.courses[i].course < plan.courses[j].course
+	})
+	return plan
+}
+
+func runPlan(c *cli, args []string) error {
+	if c.planDays < 0 {
+		return &exitCodeError{code: exitUsage, err: fmt.Errorf("--days must be 0 or more, got %d", c.planDays)}
+	}
+	// Grades give each course's recovery a starting point
+	reports, err := c.loadReports(args, append(slices.Clone(assignmentSections), sectionGrades))
+	if err != nil {
+		return err
+	}
+	var students []studentData
+	for _, r := range reports {
+		collected, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		students = append(students, collected...)
+	}
+	if len(students) == 0 {
+		fmt.Println(noStudentsMessage)
+		return nil
+	}
+
+	now := time.Now()
+	horizon := time.Duration(c.planDays) * 24 * time.Hour
+	var plans []studentPlan
+	problems := 0
+	for _, data := range students {
+		plans = append(plans, buildPlan(data, horizon, now))
+		problems += len(data.problems)
+	}
+
+	switch c.format {
+	case "json":
+		if err := writeJSON(os.Stdout, plansJSON(plans)); err != nil {
+			return err
+		}
+	case "markdown":
+		for i, plan := range plans {
+			if i > 0 {
+				fmt.Println()
+			}
+			printPlanMarkdown(os.Stdout, plan)
+		}
+	default:
+		for _, plan := range plans {
+			printPlan(os.Stdout, plan)
+		}
+	}
+	return reports[0].checkStrict(problems)
+}
+
+func printPlan(w io.Writer, plan studentPlan) {
+	printHeader(w, plan.name)
+	if len(plan.items) == 0 {
+		color.New(color.FgGreen).Fprintln(w, "  Nothing to catch up on.")
+	} else {
+		color.New(color.FgRed, color.Bold).Fprintf(w, "PLAN (%d to do)\n", len(plan.items))
+		printPlanTable(w, plan.items)
+
+		fmt.Fprintln(w)
+		color.New(color.FgMagenta, color.Bold).Fprintln(w, "RECOVERY BY COURSE")
+		table := tablewriter.NewWriter(w)
+		table.Configure(func(cfg *tablewriter.Config) {
+			cfg.Row.Alignment.PerColumn = []tw.Align{tw.AlignLeft, tw.AlignRight, tw.AlignRight, tw.AlignRight}
+		})
+		table.Header("Subject", "To do", "Now", "Up to")
+		for _, cr := range plan.courses {
+			table.Append(hyperlink(truncateString(cr.course, 40), cr.url), fmt.Sprintf("%d", cr.count), cr.formatCurrent(), formatGain(cr.gain))
+		}
+		table.Render()
+	}
+	if len(plan.problems) > 0 {
+		fmt.Fprintln(w)
+		printProblems(w, plan.problems)
+	}
+}
+
+func printPlanTable(w io.Writer, items []planItem) {
+	table := tablewriter.NewWriter(w)
+	table.Configure(func(cfg *tablewriter.Config) {
+		cfg.Row.Formatting.AutoWrap = tw.WrapTruncate
+		cfg.Row.Alignment.PerColumn = []tw.Align{
+			tw.AlignRight, // #
+			tw.AlignLeft,  // Subject
+			tw.AlignLeft,  // Assignment
+			tw.AlignLeft,  // Due
+			tw.AlignRight, // Pts
+			tw.AlignRight, // Gain
+			tw.AlignLeft,  // Why
+		}
+	})
+	table.Header("#", "Subject", "Assignment", "Due", "Pts", "Gain", "Why")
+
+	red := color.New(color.FgRed)
+	for i, item := range items {
+		a := item.assignment
+		pts := ""
+		if a.PointsPossible != nil {
+			pts = fmt.Sprintf("%d", int(*a.PointsPossible))
+		}
+		reason := item.reason
+		if a.DueAt.Before(time.Now()) {
+			reason = red.Sprint(reason)
+		}
+		table.Append(
+			fmt.Sprintf("%d", i+1),
+			hyperlink(truncateString(a.CourseName, 22), a.CourseURL),
+			hyperlink(truncateString(a.Name, 36), a.URL),
+			strings.ToLower(a.DueAt.Local().Format("Mon 1/2 3pm")),
+			pts,
+			formatImpact(a.Impact),
+			reason,
+		)
+	}
+	table.Render()
+}
+
+// formatCurrent shows the course's current grade, or "-" without one.
+func (cr courseRecovery) formatCurrent() string {
+	if cr.current == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", *cr.current)
+}
+
+// formatGain shows an estimated grade recovery, or "-" for none.
+func formatGain(gain float64) string {
+	if gain < 0.05 {
+		return "-"
+	}
+	return fmt.Sprintf("+%.1f%%", gain)
+}
+
+func printPlanMarkdown(w io.Writer, plan studentPlan) {
+	fmt.Fprintf(w, "## %s\n\n", mdEscape(plan.name))
+	if len(plan.items) == 0 {
+		fmt.Fprintln(w, "Nothing to catch up on.")
+	} else {
+		for i, item := range plan.items {
+			a := item.assignment
+			line := fmt.Sprintf("%d. %s · %s · %s", i+1, mdLink(a.Name, a.URL), mdEscape(a.CourseName),
+				strings.ToLower(a.DueAt.Local().Format("Mon 1/2 3pm")))
+			if impact := formatImpact(a.Impact); impact != "-" {
+				line += " · " + impact
+			}
+			fmt.Fprintln(w, line+" · _"+mdEscape(item.reason)+"_")
+		}
+		fmt.Fprint(w, "\n### Recovery by course\n\n")
+		fmt.Fprintln(w, "| Subject | To do | Now | Up to |")
+		fmt.Fprintln(w, "|---|--:|--:|--:|")
+		for _, cr := range plan.courses {
+			fmt.Fprintf(w, "| %s | %d | %s | %s |\n", mdLink(cr.course, cr.url), cr.count, cr.formatCurrent(), formatGain(cr.gain))
+		}
+	}
+	if len(plan.problems) > 0 {
+		fmt.Fprintf(w, "\n### ⚠️ DATA PROBLEMS (%d)\n\n", len(plan.problems))
+		for _, p := range plan.problems {
+			fmt.Fprintf(w, "- %s: %s. %s\n", mdEscape(p.where()), mdEscape(p.kind()), mdEscape(p.detail()))
+		}
+	}
+}
+
+type jsonPlanItem struct {
+	Rank       int            `json:"rank"`
+	Score      float64        `json:"score"`
+	Reason     string         `json:"reason"`
+	Assignment jsonAssignment `json:"assignment"`
+}
+
+type jsonRecovery struct {
+	CourseID int      `json:"course_id"`
+	Course   string   `json:"course"`
+	URL      string   `json:"url"`
+	Count    int      `json:"count"`
+	Current  *float64 `json:"current,omitempty"`
+	Gain     float64  `json:"gain"`
+}
+
+type jsonStudentPlan struct {
+	Name     string         `json:"name"`
+	Plan     []jsonPlanItem `json:"plan"`
+	Courses  []jsonRecovery `json:"courses"`
+	Problems []jsonProblem  `json:"problems,omitempty"`
+}
+
+func plansJSON(plans []studentPlan) []jsonStudentPlan {
+	out := []jsonStudentPlan{}
+	for _, plan := range plans {
+		sp := jsonStudentPlan{Name: plan.name, Plan: []jsonPlanItem{}, Courses: []jsonRecovery{}, Problems: problemsJSON(plan.problems)}
+		for i, item := range plan.items {
+			sp.Plan = append(sp.Plan, jsonPlanItem{
+				Rank:       i + 1,
+				Score:      math.Round(item.score*100) / 100,
+				Reason:     item.reason,
+				Assignment: assignmentJSON(item.assignment),
+			})
+		}
+		for _, cr := range plan.courses {
+			sp.Courses = append(sp.Courses, jsonRecovery{CourseID: cr.courseID, Course: cr.course, URL: cr.url, Count: cr.count, Current: cr.current, Gain: cr.gain})
+		}
+		out = append(out, sp)
+	}
+	return out
+}
@@ -0,0 +1,340 @@
+// ABOUTME: Planner-based to-do view for canvas-report.
+// ABOUTME: Lists Canvas planner items (quizzes, discussions, pages, notes) plus undated assignments per student.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+const plannerHorizon = 14 * 24 * time.Hour
+
+type plannerEntry struct {
+	Date       *time.Time `json:"date"`
+	CourseName string     `json:"course"`
+	Type       string     `json:"type"`
+	Title      string     `json:"title"`
+	Points     *float64   `json:"points_possible"`
+	Done       bool       `json:"done"`
+	Missing    bool       `json:"missing"`
+	URL        string     `json:"url,omitempty"`
+}
+
+type plannerData struct {
+	name     string
+	dated    []plannerEntry
+	undated  []plannerEntry
+	problems []dataProblem
+}
+
+// generatePlanners prints each student's planner to-do list for the next two
+// weeks, followed by any undated assignments they haven't turned in.
+func generatePlanners(reports []*Report) error {
+	var all []plannerData
+	for _, r := range reports {
+		observees, err := r.observees()
+		if err != nil {
+			return err
+		}
+		for _, student := range observees {
+			data, err := r.fetchPlannerData(student)
+			if err != nil {
+				return err
+			}
+			all = append(all, data)
+		}
+	}
+
+	if len(all) == 0 {
+		fmt.Println(noStudentsMessage)
+		return nil
+	}
+
+	r := reports[0]
+	if r.format == "json" {
+		type studentPlanner struct {
+			Name     string         `json:"name"`
+			Dated    []plannerEntry `json:"dated"`
+			Undated  []plannerEntry `json:"undated"`
+			Problems []jsonProblem  `json:"problems,omitempty"`
+		}
+		var out []studentPlanner
+		for _, data := range all {
+			// Empty lists rather than null, so scripts can always iterate
+			p := studentPlanner{Name: data.name, Dated: []plannerEntry{}, Undated: []plannerEntry{}, Problems: problemsJSON(data.problems)}
+			p.Dated = append(p.Dated, data.dated...)
+			p.Undated = append(p.Undated, data.undated...)
+			out = append(out, p)
+		}
+		if err := writeJSON(os.Stdout, out); err != nil {
+			return err
+		}
+		return r.checkStrict(countProblems(all))
+	}
+
+	for i, data := range all {
+		if i > 0 {
+			fmt.Println()
+		}
+		if r.format == "markdown" {
+			printPlannerMarkdown(os.Stdout, data)
+			continue
+		}
+		r.printPlanner(data)
+	}
+
+	return r.checkStrict(countProblems(all))
+}
+
+func countProblems(all []plannerData) int {
+	n := 0
+	for _, data := range all {
+		n += len(data.problems)
+	}
+	return n
+}
+
+func (r *Report) fetchPlannerData(student Observee) (plannerData, error) {
+	name := studentName(student)
+
+	defer r.client.tracer.timeStudent(name, time.Now())
+
+	s := r.newSpinner()
+	s.Prefix = "["
+	s.Suffix = fmt.Sprintf("] %s: fetching planner...", name)
+	s.Start()
+
+	start := truncateToDay(time.Now())
+	items, err := r.client.PlannerItems(student.ID, start, start.Add(plannerHorizon))
+	if err != nil {
+		s.Stop()
+		return plannerData{}, err
+	}
+
+	s.Suffix = fmt.Sprintf("] %s: fetching undated assignments...", name)
+	courses, err := r.courses(student)
+	if err != nil {
+		s.Stop()
+		return plannerData{}, err
+	}
+	problems := &problemLog{}
+	undated := r.fetchUndatedAssignments(courses, student.ID, problems)
+
+	s.Stop()
+	if !r.quiet {
+		fmt.Fprintf(os.Stderr, "[✔] %s: %d planner items, %d undated\n", name, len(items), len(undated))
+	}
+
+	courseNames := make(map[int]string, len(courses))
+	for _, c := range courses {
+		courseNames[c.ID] = r.names.name(c)
+	}
+
+	var dated []plannerEntry
+	for _, item := range items {
+		courseName, ok := courseNames[item.CourseID]
+		// Personal notes have no course; anything else must be in a course
+		// the filters kept
+		if item.CourseID != 0 && !ok {
+			continue
+		}
+		entry := plannerEntryFromItem(item)
+		entry.URL = r.client.webURL(item.HTMLURL)
+		if ok {
+			entry.CourseName = courseName
+		}
+		dated = append(dated, entry)
+	}
+
+	sort.SliceStable(dated, func(i, j int) bool {
+		if dated[i].Date == nil || dated[j].Date == nil {
+			return dated[j].Date == nil && dated[i].Date != nil
+		}
+		return dated[i].Date.Before(*dated[j].Date)
+	})
+	sort.Slice(undated, func(i, j int) bool {
+		if undated[i].CourseName != undated[j].CourseName {
+			return undated[i].CourseName < undated[j].CourseName
+		}
+		return undated[i].Title < undated[j].Title
+	})
+
+	r.names.addProblem(problems)
+	return plannerData{name: name, dated: dated, undated: undated, problems: problems.list()}, nil
+}
+
+func (r *Report) fetchUndatedAssignments(courses []Course, studentID int, problems *problemLog) []plannerEntry {
+	var entries []plannerEntry
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, course := range courses {
+		wg.Add(1)
+		go func(c Course) {
+			defer wg.Done()
+
+			assignments, err := r.client.UndatedAssignments(studentID, c.ID)
+			if err != nil {
+				problems.add(r.names.name(c), atStage("undated assignments", err), "course left out of undated list")
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, a := range assignments {
+				if isCompleted(a.Submission) {
+					continue
+				}
+				entries = append(entries, plannerEntry{
+					CourseName: r.names.name(c),
+					Type:       "Assignment",
+					Title:      a.Name,
+					Points:     a.PointsPossible,
+					Missing:    a.Submission != nil && a.Submission.Missing,
+					URL:        r.client.assignmentURL(a, c.ID),
+				})
+			}
+		}(course)
+	}
+
+	wg.Wait()
+
+	return entries
+}
+
+func plannerEntryFromItem(item PlannerItem) plannerEntry {
+	entry := plannerEntry{
+		Date:       item.PlannableDate,
+		CourseName: item.ContextName,
+		Type:       plannableTypeLabel(item.PlannableType),
+		Title:      item.Plannable.Title,
+		Points:     item.Plannable.PointsPossible,
+	}
+	if entry.Date == nil {
+		if item.Plannable.DueAt != nil {
+			entry.Date = item.Plannable.DueAt
+		} else {
+			entry.Date = item.Plannable.TodoDate
+		}
+	}
+	if entry.CourseName == "" {
+		entry.CourseName = "Personal"
+	}
+
+	if sub := item.SubmissionState(); sub != nil {
+		entry.Done = sub.Submitted || sub.Graded || sub.Excused
+		entry.Missing = sub.Missing
+	}
+	if item.PlannerOverride != nil && item.PlannerOverride.MarkedComplete {
+		entry.Done = true
+	}
+
+	return entry
+}
+
+func plannableTypeLabel(t string) string {
+	switch t {
+	case "assignment":
+		return "Assignment"
+	case "quiz":
+		return "Quiz"
+	case "discussion_topic":
+		return "Discussion"
+	case "wiki_page":
+		return "Page"
+	case "planner_note":
+		return "Note"
+	case "calendar_event":
+		return "Event"
+	case "assessment_request":
+		return "Peer Review"
+	default:
+		return strings.ReplaceAll(t, "_", " ")
+	}
+}
+
+func (r *Report) printPlanner(data plannerData) {
+	printHeader(os.Stdout, data.name)
+
+	yellow := color.New(color.FgYellow, color.Bold)
+	cyan := color.New(color.FgCyan, color.Bold)
+	dim := color.New(color.Faint)
+
+	pending := 0
+	for _, e := range data.dated {
+		if !e.Done {
+			pending++
+		}
+	}
+
+	yellow.Printf("TO DO - NEXT 2 WEEKS (%d pending)\n", pending)
+	if len(data.dated) == 0 {
+		dim.Println("  Nothing in the planner.")
+	} else {
+		printPlannerTable(data.dated)
+	}
+
+	if len(data.undated) > 0 {
+		fmt.Println()
+		cyan.Printf("UNDATED (%d)\n", len(data.undated))
+		printPlannerTable(data.undated)
+	}
+
+	if len(data.problems) > 0 {
+		fmt.Println()
+		printProblems(os.Stdout, data.problems)
+	}
+}
+
+func printPlannerTable(entries []plannerEntry) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Configure(func(cfg *tablewriter.Config) {
+		cfg.Row.Formatting.AutoWrap = tw.WrapTruncate
+		cfg.Row.Alignment.PerColumn = []tw.Align{
+			tw.AlignLeft,  // Due
+			tw.AlignLeft,  // Subject
+			tw.AlignLeft,  // Type
+			tw.AlignLeft,  // Item
+			tw.AlignRight, // Pts
+			tw.AlignLeft,  // Status
+		}
+	})
+	table.Header("Due", "Subject", "Type", "Item", "Pts", "")
+
+	red := color.New(color.FgRed)
+	green := color.New(color.FgGreen)
+	dim := color.New(color.Faint)
+
+	for _, e := range entries {
+		due := "undated"
+		if e.Date != nil {
+			due = strings.ToLower(e.Date.Local().Format("Mon 1/2 3pm"))
+		}
+		subject := truncateString(e.CourseName, 22)
+		title := hyperlink(truncateString(e.Title, 45), e.URL)
+		pts := ""
+		if e.Points != nil {
+			pts = fmt.Sprintf("%d", int(*e.Points))
+		}
+
+		switch {
+		case e.Done:
+			table.Append(dim.Sprint(due), dim.Sprint(subject), dim.Sprint(e.Type), dim.Sprint(title), dim.Sprint(pts), green.Sprint("✓"))
+		case e.Missing:
+			table.Append(due, subject, e.Type, title, pts, red.Sprint("✗"))
+		default:
+			table.Append(due, subject, e.Type, title, pts, "")
+		}
+	}
+
+	table.Render()
+}
@@ -4,7 +4,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
@@ -18,11 +20,45 @@ import (
 	"golang.org/x/term"
 )
 
-const oneMonthAgo = 30 * 24 * time.Hour
+// Report section kinds, in the order the full report shows them.
+const (
+	sectionMissing     = "missing"
+	sectionUnconfirmed = "unconfirmed"
+	sectionUpcoming    = "upcoming"
+	sectionWeekAhead   = "week_ahead"
+	sectionGrades      = "grades"
+)
+
+var allSections = []string{sectionMissing, sectionUnconfirmed, sectionUpcoming, sectionWeekAhead, sectionGrades}
 
 type Report struct {
-	client  *CanvasClient
-	showAll bool
+	profile  string
+	client   *CanvasClient
+	showAll  bool
+	strict   bool
+	check    bool // One status line per student
+	quiet    bool // No progress output on stderr
+	filters  Filters
+	names    *courseNamer
+	sections []string
+	format   string
+	compact  bool // Markdown bullet lists instead of tables
+	layout   ReportConfig
+	cutoff   time.Duration // How old missing work can be without --all
+}
+
+type ReportOptions struct {
+	Profile     string            // Name of the config profile the client belongs to
+	ShowAll     bool              // Include missing work older than the cutoff
+	Strict      bool              // Fail when any data couldn't be fetched
+	Check       bool              // Print one status line per student and exit with its status
+	Quiet       bool              // Skip progress output; implied by Check
+	Filters     Filters           // Which students and courses to fetch
+	CourseNames map[string]string // Display-name aliases keyed by course ID or name pattern
+	Sections    []string          // Section kinds to build and show; nil means the configured layout
+	Format      string            // Output format: "text", "json" or "markdown"
+	Compact     bool              // Markdown bullet lists instead of tables
+	Layout      ReportConfig      // Section order, windows, sorts and missing cutoff; validated
 }
 
 type columnWidths struct {
@@ -102,27 +138,51 @@ type AssignmentImpact struct {
 	Gain       float64 // Max improvement if 100% (positive number)
 	Loss       float64 // Loss if 0% (positive number)
 	IsWeighted bool    // Determines display format (% vs pts)
+	Penalty    float64 // Percent of the points the late policy would deduct, already taken out of Gain
 }
 
 type EnrichedAssignment struct {
+	ID             int
+	CourseID       int
 	Name           string
 	CourseName     string
 	CategoryName   string // Weighted category (e.g., "Summative", "Formative")
+	GroupName      string // Assignment group, whether or not the course is weighted
 	DueAt          time.Time
 	PointsPossible *float64
 	Submission     *Submission
 	Status         string
 	Impact         *AssignmentImpact
+	URL            string // Assignment page in Canvas
+	CourseURL      string
+}
+
+type reportSection struct {
+	kind        string
+	title       string
+	empty       string // Shown instead of an empty table
+	assignments []EnrichedAssignment
+	pending     int // Assignments not yet completed
 }
 
 type studentData struct {
-	name             string
-	missing          []EnrichedAssignment
-	upcoming         []EnrichedAssignment
-	weekAhead        []EnrichedAssignment
-	upcomingPending  int
-	weekAheadPending int
-	grades           []periodGrades
+	id          int
+	name        string
+	sections    []reportSection
+	assignments []EnrichedAssignment // Every dated assignment, with the missing sections' statuses
+	grades      []periodGrades
+	problems    []dataProblem
+}
+
+// section returns the student's section of the given kind, or nil if the
+// report doesn't include it.
+func (d studentData) section(kind string) *reportSection {
+	for i := range d.sections {
+		if d.sections[i].kind == kind {
+			return &d.sections[i]
+		}
+	}
+	return nil
 }
 
 type periodGrades struct {
@@ -131,7 +191,9 @@ type periodGrades struct {
 }
 
 type CourseGrade struct {
+	CourseID       int
 	CourseName     string
+	URL            string // Course home page in Canvas
 	Points         float64
 	PointsPossible float64
 	Percent        float64
@@ -147,32 +209,167 @@ type CategoryGrade struct {
 	Weight         float64
 }
 
-func NewReport(client *CanvasClient, showAll bool) *Report {
-	return &Report{client: client, showAll: showAll}
+func NewReport(client *CanvasClient, opts ReportOptions) *Report {
+	sections := opts.Layout.order(opts.Sections)
+	cutoff, err := opts.Layout.cutoff()
+	if err != nil {
+		cutoff = defaultMissingCutoff
+	}
+	format := opts.Format
+	if format == "" {
+		format = "text"
+	}
+	return &Report{
+		profile:  opts.Profile,
+		client:   client,
+		showAll:  opts.ShowAll,
+		strict:   opts.Strict,
+		check:    opts.Check,
+		quiet:    opts.Quiet || opts.Check,
+		filters:  opts.Filters,
+		names:    newCourseNamer(client, opts.CourseNames),
+		sections: sections,
+		format:   format,
+		compact:  opts.Compact,
+		layout:   opts.Layout,
+		cutoff:   cutoff,
+	}
+}
+
+func (r *Report) wants(kind string) bool {
+	for _, s := range r.sections {
+		if s == kind {
+			return true
+		}
+	}
+	return false
 }
 
-func (r *Report) Generate() error {
+// wantsAssignments reports whether any assignment section is requested, so
+// grade-only runs can skip fetching assignments entirely.
+func (r *Report) wantsAssignments() bool {
+	for _, s := range r.sections {
+		if s != sectionGrades {
+			return true
+		}
+	}
+	return false
+}
+
+// observees returns the observed students that pass the student filter.
+func (r *Report) observees() ([]Observee, error) {
 	observees, err := r.client.Observees()
 	if err != nil {
+		return nil, err
+	}
+	return r.filters.filterStudents(observees), nil
+}
+
+// courses returns the student's active courses that pass the course filters,
+// so excluded courses are never fetched.
+func (r *Report) courses(student Observee) ([]Course, error) {
+	courses, err := r.client.Courses(student.ID)
+	if err != nil {
+		return nil, err
+	}
+	courses = r.filters.filterCourses(student, courses)
+	if r.client.tracer != nil {
+		for _, c := range courses {
+			r.client.tracer.nameCourse(c.ID, r.names.name(c))
+		}
+	}
+	return courses, nil
+}
+
+// newSpinner returns a progress spinner on stderr, silenced in quiet mode
+// and when request traces are going there too.
+func (r *Report) newSpinner() *spinner.Spinner {
+	w := io.Writer(os.Stderr)
+	if r.quiet || r.client.tracer.logsTo(os.Stderr) {
+		w = io.Discard
+	}
+	return spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriter(w))
+}
+
+const noStudentsMessage = "No observed students found. Make sure you have parent observer access set up in Canvas."
+
+// generateReports fetches every report's students (one report per Canvas
+// profile) and renders them together using the first report's settings.
+func generateReports(reports []*Report) error {
+	var allStudents []studentData
+	for _, r := range reports {
+		students, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		allStudents = append(allStudents, students...)
+	}
+	if reports[0].check {
+		return reports[0].renderCheck(allStudents)
+	}
+	if err := reports[0].Render(os.Stdout, allStudents); err != nil {
 		return err
 	}
 
-	if len(observees) == 0 {
-		fmt.Println("No observed students found. Make sure you have parent observer access set up in Canvas.")
+	problems := 0
+	for _, data := range allStudents {
+		problems += len(data.problems)
+	}
+	return reports[0].checkStrict(problems)
+}
+
+// checkStrict turns data problems into a failing exit status when --strict
+// is set.
+func (r *Report) checkStrict(problems int) error {
+	if !r.strict || problems == 0 {
 		return nil
 	}
+	return &exitCodeError{code: exitDataProblems, err: fmt.Errorf("%d data problem(s); the report is incomplete", problems)}
+}
 
-	// Fetch all student data first
-	var allStudents []studentData
-	var allAssignments [][]EnrichedAssignment
+// Collect fetches data for every student that passes the filters.
+func (r *Report) Collect() ([]studentData, error) {
+	observees, err := r.observees()
+	if err != nil {
+		return nil, err
+	}
 
+	var allStudents []studentData
 	for _, student := range observees {
 		data, err := r.fetchStudentData(student)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		allStudents = append(allStudents, data)
-		allAssignments = append(allAssignments, data.missing, data.upcoming, data.weekAhead)
+	}
+	return allStudents, nil
+}
+
+// Render writes collected student data to w in the report's output format.
+func (r *Report) Render(w io.Writer, allStudents []studentData) error {
+	if len(allStudents) == 0 {
+		fmt.Fprintln(w, noStudentsMessage)
+		return nil
+	}
+
+	switch r.format {
+	case "json":
+		return writeJSON(w, reportJSON(allStudents))
+	case "markdown":
+		renderMarkdown(w, allStudents, r.compact)
+		return nil
+	}
+
+	r.printStudents(w, allStudents)
+	return nil
+}
+
+func (r *Report) printStudents(w io.Writer, allStudents []studentData) {
+	var allAssignments [][]EnrichedAssignment
+	for _, data := range allStudents {
+		for _, sec := range data.sections {
+			allAssignments = append(allAssignments, sec.assignments)
+		}
 	}
 
 	// Calculate column widths across ALL students' data
@@ -184,67 +381,110 @@ func (r *Report) Generate() error {
 
 	for i, data := range allStudents {
 		if i > 0 {
-			fmt.Println()
-			fmt.Println(strings.Repeat("═", tableWidth))
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, strings.Repeat("═", tableWidth))
 		}
-		r.printReport(data, colWidths)
+		r.printReport(w, data, colWidths)
 	}
-
-	return nil
 }
 
-func (r *Report) fetchStudentData(student Observee) (studentData, error) {
-	name := student.Name
-	if name == "" {
-		name = student.ShortName
+func studentName(student Observee) string {
+	if student.Name != "" {
+		return student.Name
 	}
-	if name == "" {
-		name = "Unknown Student"
+	if student.ShortName != "" {
+		return student.ShortName
 	}
+	return "Unknown Student"
+}
+
+func (r *Report) fetchStudentData(student Observee) (studentData, error) {
+	name := studentName(student)
+
+	defer r.client.tracer.timeStudent(name, time.Now())
 
-	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	s := r.newSpinner()
 	s.Prefix = fmt.Sprintf("[")
 	s.Suffix = fmt.Sprintf("] %s: fetching courses...", name)
 	s.Start()
 
-	courses, err := r.client.Courses(student.ID)
+	courses, err := r.courses(student)
 	if err != nil {
 		s.Stop()
 		return studentData{}, err
 	}
 
-	s.Suffix = fmt.Sprintf("] %s: 0/%d courses...", name, len(courses))
-
-	assignments := r.fetchAllAssignments(courses, student.ID, s, name)
+	problems := &problemLog{}
+	var assignments []EnrichedAssignment
+	var canvasMissing map[assignmentKey]bool
+	if r.wantsAssignments() {
+		s.Suffix = fmt.Sprintf("] %s: 0/%d courses...", name, len(courses))
+		assignments = r.fetchAllAssignments(courses, student.ID, s, name, problems)
+
+		if r.wants(sectionMissing) || r.wants(sectionUnconfirmed) {
+			s.Suffix = fmt.Sprintf("] %s: fetching missing submissions...", name)
+			canvasMissing, err = r.fetchCanvasMissing(student.ID)
+			if err != nil {
+				problems.add("", atStage("missing flags", err), "missing work is guessed from due dates")
+			}
+		}
+	}
 
-	s.Suffix = fmt.Sprintf("] %s: fetching grades...", name)
-	grades := r.fetchAllGrades(courses, student.ID)
+	var grades []periodGrades
+	if r.wants(sectionGrades) {
+		s.Suffix = fmt.Sprintf("] %s: fetching grades...", name)
+		grades = r.fetchAllGrades(courses, student.ID, problems)
+	}
 
 	s.Stop()
 	gradeCount := 0
 	for _, pg := range grades {
 		gradeCount += len(pg.grades)
 	}
-	fmt.Fprintf(os.Stderr, "[✔] %s: %d courses, %d assignments, %d grades\n", name, len(courses), len(assignments), gradeCount)
+	if !r.quiet {
+		fmt.Fprintf(os.Stderr, "[✔] %s: %d courses, %d assignments, %d grades\n", name, len(courses), len(assignments), gradeCount)
+	}
 
-	missing := r.missingAssignments(assignments)
-	upcoming := r.upcomingAssignments(assignments)
-	weekAhead := r.weekAheadAssignments(assignments)
+	r.names.addProblem(problems)
+	data := studentData{id: student.ID, name: name, grades: grades, problems: problems.list()}
 
-	return studentData{
-		name:             name,
-		missing:          missing,
-		upcoming:         upcoming,
-		weekAhead:        weekAhead,
-		upcomingPending:  countPending(upcoming),
-		weekAheadPending: countPending(weekAhead),
-		grades:           grades,
-	}, nil
+	missing, unconfirmed := r.missingAssignments(assignments, canvasMissing)
+	data.assignments = withStatuses(assignments, missing, unconfirmed)
+	upcoming, weekAhead := r.windowed(assignments, time.Now())
+	built := map[string][]EnrichedAssignment{
+		sectionMissing:     missing,
+		sectionUnconfirmed: unconfirmed,
+		sectionUpcoming:    upcoming,
+		sectionWeekAhead:   weekAhead,
+	}
+	for _, kind := range r.sections {
+		list, ok := built[kind]
+		if !ok {
+			continue
+		}
+		sc := r.layout.section(kind)
+		sortAssignments(list, sc.Sort)
+		data.sections = append(data.sections, reportSection{
+			kind:        kind,
+			title:       sc.Title,
+			empty:       sc.empty(),
+			assignments: list,
+			pending:     countPending(list),
+		})
+	}
+
+	return data, nil
+}
+
+var sectionTitles = map[string]string{
+	sectionMissing:     "MISSING/INCOMPLETE",
+	sectionUnconfirmed: "NOT MARKED MISSING BY CANVAS",
+	sectionUpcoming:    "DUE TODAY/TOMORROW",
+	sectionWeekAhead:   "WEEK AHEAD",
 }
 
-func (r *Report) fetchAllAssignments(courses []Course, studentID int, s *spinner.Spinner, studentName string) []EnrichedAssignment {
+func (r *Report) fetchAllAssignments(courses []Course, studentID int, s *spinner.Spinner, studentName string, problems *problemLog) []EnrichedAssignment {
 	var assignments []EnrichedAssignment
-	var errors []string
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	completed := 0
@@ -255,12 +495,12 @@ func (r *Report) fetchAllAssignments(courses []Course, studentID int, s *spinner
 		go func(c Course) {
 			defer wg.Done()
 
-			courseAssignments, err := r.fetchCourseAssignments(c, studentID)
-
-			mu.Lock()
+			courseAssignments, err := r.fetchCourseAssignments(c, studentID, problems)
 			if err != nil {
-				errors = append(errors, fmt.Sprintf("%s: %v", c.Name, err))
+				problems.add(r.names.name(c), err, "course left out of assignment sections")
 			}
+
+			mu.Lock()
 			assignments = append(assignments, courseAssignments...)
 			completed++
 			s.Suffix = fmt.Sprintf("] %s: %d/%d courses...", studentName, completed, total)
@@ -270,29 +510,30 @@ func (r *Report) fetchAllAssignments(courses []Course, studentID int, s *spinner
 
 	wg.Wait()
 
-	for _, e := range errors {
-		fmt.Fprintf(os.Stderr, "  warning: %s\n", e)
-	}
-
 	return assignments
 }
 
-func (r *Report) fetchCourseAssignments(course Course, studentID int) ([]EnrichedAssignment, error) {
+// fetchCourseAssignments returns the course's dated assignments. Failing to
+// fetch assignments or submissions is an error; failures that only affect
+// grade impact are recorded in problems and the assignments still returned.
+func (r *Report) fetchCourseAssignments(course Course, studentID int, problems *problemLog) ([]EnrichedAssignment, error) {
 	var result []EnrichedAssignment
+	courseName := r.names.name(course)
 
 	rawAssignments, err := r.client.Assignments(course.ID)
 	if err != nil {
-		return result, fmt.Errorf("fetching assignments: %w", err)
+		return result, atStage("assignments", err)
 	}
 
 	rawSubmissions, err := r.client.Submissions(course.ID, studentID)
 	if err != nil {
-		return result, fmt.Errorf("fetching submissions: %w", err)
+		return result, atStage("submissions", err)
 	}
 
 	// Fetch assignment groups for impact calculation
 	groups, err := r.client.AssignmentGroups(course.ID)
 	if err != nil {
+		problems.add(courseName, atStage("assignment groups", err), "no grade impact shown")
 		groups = nil // Continue without impact if groups fail
 	}
 
@@ -301,11 +542,17 @@ func (r *Report) fetchCourseAssignments(course Course, studentID int) ([]Enriche
 	var currentPeriod *GradingPeriod
 	weighted := isWeightedGrading(groups)
 	if groups != nil {
-		periods, _ := r.client.GradingPeriods(course.ID)
+		periods, err := r.client.GradingPeriods(course.ID)
+		if err != nil {
+			problems.add(courseName, atStage("grading periods", err), "impact covers the whole course")
+		}
 		currentPeriod = currentGradingPeriod(periods)
 		if currentPeriod != nil {
 			periodID := fmt.Sprintf("%v", currentPeriod.ID)
-			enrollments, _ := r.client.Enrollments(course.ID, studentID, periodID)
+			enrollments, err := r.client.Enrollments(course.ID, studentID, periodID)
+			if err != nil {
+				problems.add(courseName, atStage("enrollment", err), "impact assumes a current grade of 0%")
+			}
 			if len(enrollments) > 0 && enrollments[0].Grades.CurrentScore != nil {
 				currentOverall = *enrollments[0].Grades.CurrentScore
 			}
@@ -315,49 +562,57 @@ func (r *Report) fetchCourseAssignments(course Course, studentID int) ([]Enriche
 	// Calculate impacts
 	var impacts map[int]*AssignmentImpact
 	if groups != nil {
-		impacts = calculateAssignmentImpacts(groups, rawSubmissions, currentOverall, weighted, currentPeriod)
+		// Courses without a late policy answer 404
+		policy, err := r.client.LatePolicy(course.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			problems.add(courseName, atStage("late policy", err), "impact ignores late penalties")
+		}
+		impacts = calculateAssignmentImpacts(groups, rawSubmissions, currentOverall, weighted, currentPeriod, policy, time.Now())
 	}
 
-	// Build map of assignment ID to category name (only for weighted courses)
-	categoryByAssignment := make(map[int]string)
-	if weighted && groups != nil {
-		for _, group := range groups {
-			for _, a := range group.Assignments {
-				categoryByAssignment[a.ID] = group.Name
-			}
+	// Build map of assignment ID to group name; it's only shown as a
+	// category for weighted courses
+	groupByAssignment := make(map[int]string)
+	for _, group := range groups {
+		for _, a := range group.Assignments {
+			groupByAssignment[a.ID] = group.Name
 		}
 	}
+	categoryByAssignment := groupByAssignment
+	if !weighted {
+		categoryByAssignment = nil
+	}
 
 	submissionsByID := make(map[int]*Submission)
 	for i := range rawSubmissions {
 		submissionsByID[rawSubmissions[i].AssignmentID] = &rawSubmissions[i]
 	}
 
-	courseName := course.Name
-	if courseName == "" {
-		courseName = "Unknown Course"
-	}
-
 	for _, a := range rawAssignments {
 		if a.DueAt == nil {
 			continue
 		}
 
 		result = append(result, EnrichedAssignment{
+			ID:             a.ID,
+			CourseID:       course.ID,
 			Name:           a.Name,
 			CourseName:     courseName,
 			CategoryName:   categoryByAssignment[a.ID],
+			GroupName:      groupByAssignment[a.ID],
 			DueAt:          *a.DueAt,
 			PointsPossible: a.PointsPossible,
 			Submission:     submissionsByID[a.ID],
 			Impact:         impacts[a.ID],
+			URL:            r.client.assignmentURL(a, course.ID),
+			CourseURL:      r.client.courseURL(course.ID),
 		})
 	}
 
 	return result, nil
 }
 
-func (r *Report) fetchAllGrades(courses []Course, studentID int) []periodGrades {
+func (r *Report) fetchAllGrades(courses []Course, studentID int, problems *problemLog) []periodGrades {
 	type courseGradeResult struct {
 		period *GradingPeriod
 		grade  *CourseGrade
@@ -372,7 +627,10 @@ func (r *Report) fetchAllGrades(courses []Course, studentID int) []periodGrades
 		go func(c Course) {
 			defer wg.Done()
 
-			period, grade := r.fetchCourseGrade(c, studentID)
+			period, grade, err := r.fetchCourseGrade(c, studentID, problems)
+			if err != nil {
+				problems.add(r.names.name(c), err, "no grade shown")
+			}
 
 			mu.Lock()
 			if period != nil && grade != nil {
@@ -422,52 +680,61 @@ func (r *Report) fetchAllGrades(courses []Course, studentID int) []periodGrades
 	return grouped
 }
 
-func (r *Report) fetchCourseGrade(course Course, studentID int) (*GradingPeriod, *CourseGrade) {
+// fetchCourseGrade returns the student's grade for the current grading
+// period. A course with no current period or no score yet has no grade and
+// no error; failures fetching it are returned as errors.
+func (r *Report) fetchCourseGrade(course Course, studentID int, problems *problemLog) (*GradingPeriod, *CourseGrade, error) {
 	periods, err := r.client.GradingPeriods(course.ID)
 	if err != nil {
-		return nil, nil
+		return nil, nil, atStage("grading periods", err)
 	}
 
 	current := currentGradingPeriod(periods)
 	if current == nil {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	// Convert grading period ID to string for API call
 	periodID := fmt.Sprintf("%v", current.ID)
 	enrollments, err := r.client.Enrollments(course.ID, studentID, periodID)
-	if err != nil || len(enrollments) == 0 {
-		return nil, nil
+	if err != nil {
+		return nil, nil, atStage("enrollment", err)
+	}
+	if len(enrollments) == 0 {
+		return nil, nil, nil
 	}
 
 	enrollment := enrollments[0]
 	if enrollment.Grades.CurrentScore == nil {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	percent := *enrollment.Grades.CurrentScore
 
-	courseName := course.Name
-	if courseName == "" {
-		courseName = "Unknown Course"
-	}
+	courseName := r.names.name(course)
 
 	// Check if course uses weighted grading
 	groups, err := r.client.AssignmentGroups(course.ID)
 	if err != nil {
+		problems.add(courseName, atStage("assignment groups", err), "shown as unweighted")
 		groups = nil
 	}
 
 	weighted := isWeightedGrading(groups)
 
 	if weighted {
-		categories := r.buildCategoryGrades(course.ID, studentID, groups, current)
+		categories, err := r.buildCategoryGrades(course.ID, studentID, groups, current)
+		if err != nil {
+			problems.add(courseName, atStage("submissions", err), "no category breakdown")
+		}
 		return current, &CourseGrade{
+			CourseID:   course.ID,
 			CourseName: courseName,
+			URL:        r.client.courseURL(course.ID),
 			Percent:    percent,
 			Weighted:   true,
 			Categories: categories,
-		}
+		}, nil
 	}
 
 	// Non-weighted: calculate points from submissions
@@ -481,12 +748,14 @@ func (r *Report) fetchCourseGrade(course Course, studentID int) (*GradingPeriod,
 	}
 
 	return current, &CourseGrade{
+		CourseID:       course.ID,
 		CourseName:     courseName,
+		URL:            r.client.courseURL(course.ID),
 		Points:         points,
 		PointsPossible: pointsPossible,
 		Percent:        percent,
 		Weighted:       false,
-	}
+	}, nil
 }
 
 func isWeightedGrading(groups []AssignmentGroup) bool {
@@ -498,11 +767,11 @@ func isWeightedGrading(groups []AssignmentGroup) bool {
 	return false
 }
 
-func (r *Report) buildCategoryGrades(courseID, studentID int, groups []AssignmentGroup, period *GradingPeriod) []CategoryGrade {
+func (r *Report) buildCategoryGrades(courseID, studentID int, groups []AssignmentGroup, period *GradingPeriod) ([]CategoryGrade, error) {
 	// Get submissions to calculate points per category
 	submissions, err := r.client.Submissions(courseID, studentID)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	// Build map of assignment ID -> score
@@ -552,7 +821,7 @@ func (r *Report) buildCategoryGrades(courseID, studentID int, groups []Assignmen
 		return categories[i].Name < categories[j].Name
 	})
 
-	return categories
+	return categories, nil
 }
 
 type categoryState struct {
@@ -573,12 +842,16 @@ func calculateAssignmentImpacts(
 	currentOverall float64,
 	weighted bool,
 	period *GradingPeriod,
+	policy *LatePolicy,
+	now time.Time,
 ) map[int]*AssignmentImpact {
 	impacts := make(map[int]*AssignmentImpact)
 
 	// Build map of assignment submission info
 	subInfoByAssignment := make(map[int]submissionInfo)
-	for _, sub := range submissions {
+	subsByAssignment := make(map[int]*Submission)
+	for i, sub := range submissions {
+		subsByAssignment[sub.AssignmentID] = &submissions[i]
 		info := submissionInfo{
 			missing: sub.Missing,
 			graded:  sub.GradedAt != nil,
@@ -652,22 +925,27 @@ func calculateAssignmentImpacts(
 				continue
 			}
 
+			// Full credit is worth less once the late policy takes its share
+			penalty := policy.penalty(lateness(subsByAssignment[a.ID], a.DueAt, now))
+			earned := pts * (1 - penalty)
+
 			if graded && score == 0 {
 				// Graded as 0: already in totals, can only improve
 				if weighted {
-					impacts[a.ID] = calculateGradedZeroWeightedImpact(state, pts, currentOverall, categoryStates)
+					impacts[a.ID] = calculateGradedZeroWeightedImpact(state, earned, currentOverall, categoryStates)
 				} else {
-					impacts[a.ID] = calculateGradedZeroNonWeightedImpact(totalPoints, totalPossible, pts, currentOverall)
+					impacts[a.ID] = calculateGradedZeroNonWeightedImpact(totalPoints, totalPossible, earned, currentOverall)
 				}
 			} else {
 				// Ungraded: not in totals yet
 				if weighted {
-					impacts[a.ID] = calculateWeightedImpact(state, pts, currentOverall, categoryStates)
+					impacts[a.ID] = calculateWeightedImpact(state, pts, earned, currentOverall, categoryStates)
 				} else {
-					impacts[a.ID] = calculateNonWeightedImpact(totalPoints, totalPossible, pts, currentOverall)
+					impacts[a.ID] = calculateNonWeightedImpact(totalPoints, totalPossible, pts, earned, currentOverall)
 				}
 			}
 			impacts[a.ID].IsWeighted = weighted
+			impacts[a.ID].Penalty = penalty * 100
 		}
 	}
 
@@ -677,6 +955,7 @@ func calculateAssignmentImpacts(
 func calculateWeightedImpact(
 	category *categoryState,
 	assignmentPts float64,
+	earnedPts float64, // What full credit is worth after any late penalty
 	currentOverall float64,
 	allCategories map[int]*categoryState,
 ) *AssignmentImpact {
@@ -714,7 +993,7 @@ func calculateWeightedImpact(
 	calcCurrentOverall := calcOverall(category.points, category.possible, category.possible > 0)
 
 	// Best case: get 100% on assignment
-	bestCatPts := category.points + assignmentPts
+	bestCatPts := category.points + earnedPts
 	bestCatPossible := category.possible + assignmentPts
 	bestOverall := calcOverall(bestCatPts, bestCatPossible, true)
 
@@ -732,6 +1011,7 @@ func calculateWeightedImpact(
 func calculateNonWeightedImpact(
 	totalPoints, totalPossible float64,
 	assignmentPts float64,
+	earnedPts float64, // What full credit is worth after any late penalty
 	currentOverall float64,
 ) *AssignmentImpact {
 	// Calculate current percentage consistently
@@ -741,7 +1021,7 @@ func calculateNonWeightedImpact(
 	}
 
 	// Best case: get 100% on assignment
-	bestPts := totalPoints + assignmentPts
+	bestPts := totalPoints + earnedPts
 	bestPossible := totalPossible + assignmentPts
 	var bestPct float64
 	if bestPossible > 0 {
@@ -868,11 +1148,37 @@ func currentGradingPeriod(periods []GradingPeriod) *GradingPeriod {
 	return nil
 }
 
-func (r *Report) missingAssignments(assignments []EnrichedAssignment) []EnrichedAssignment {
-	now := time.Now()
-	cutoff := now.Add(-oneMonthAgo)
+type assignmentKey struct {
+	courseID     int
+	assignmentID int
+}
 
-	var result []EnrichedAssignment
+// fetchCanvasMissing returns the set of assignments Canvas flags as missing
+// for the student. Assignments the student dismissed or marked complete in
+// their planner map to false, so they're left out rather than guessed at. A
+// nil map means the lookup failed and callers should fall back to the local
+// heuristic.
+func (r *Report) fetchCanvasMissing(studentID int) (map[assignmentKey]bool, error) {
+	flagged, err := r.client.MissingSubmissions(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[assignmentKey]bool, len(flagged))
+	for _, a := range flagged {
+		done := a.PlannerOverride != nil && (a.PlannerOverride.MarkedComplete || a.PlannerOverride.Dismissed)
+		result[assignmentKey{courseID: a.CourseID, assignmentID: a.ID}] = !done
+	}
+	return result, nil
+}
+
+// missingAssignments builds the missing section. When Canvas's missing flags
+// are available they decide what is missing; assignments the heuristic
+// considers missing but Canvas doesn't are returned separately as unconfirmed
+// (typically paper submissions the teacher hasn't marked yet).
+func (r *Report) missingAssignments(assignments []EnrichedAssignment, canvasMissing map[assignmentKey]bool) (missing, unconfirmed []EnrichedAssignment) {
+	now := time.Now()
+	cutoff := now.Add(-r.cutoff)
 
 	for _, a := range assignments {
 		if a.DueAt.After(now) {
@@ -882,140 +1188,203 @@ func (r *Report) missingAssignments(assignments []EnrichedAssignment) []Enriched
 			continue
 		}
 
-		sub := a.Submission
-		unsubmitted := sub != nil && sub.SubmittedAt == nil && sub.GradedAt == nil && !sub.Excused
-		isMissing := sub == nil || sub.Missing || unsubmitted || (sub.Score != nil && *sub.Score == 0 && sub.GradedAt != nil)
+		heuristic := looksMissing(a.Submission)
+
+		if canvasMissing == nil {
+			if heuristic {
+				enriched := a
+				enriched.Status = determineStatus(a)
+				missing = append(missing, enriched)
+			}
+			continue
+		}
 
-		if isMissing && !awaitingGrade(sub) {
-			enriched := a
+		flagged, listed := canvasMissing[assignmentKey{courseID: a.CourseID, assignmentID: a.ID}]
+		if listed && !flagged {
+			// Marked complete or dismissed in the planner
+			continue
+		}
+		enriched := a
+		switch {
+		case flagged && heuristic:
 			enriched.Status = determineStatus(a)
-			result = append(result, enriched)
+			missing = append(missing, enriched)
+		case flagged:
+			// Canvas says missing even though something was turned in
+			enriched.Status = "Marked missing"
+			missing = append(missing, enriched)
+		case heuristic:
+			enriched.Status = "Unconfirmed"
+			unconfirmed = append(unconfirmed, enriched)
+		}
+	}
+
+	sortByDue(missing)
+	sortByDue(unconfirmed)
+
+	return missing, unconfirmed
+}
+
+// withStatuses returns every assignment sorted by due date, carrying the
+// status it was given in the missing or unconfirmed list.
+func withStatuses(assignments []EnrichedAssignment, lists ...[]EnrichedAssignment) []EnrichedAssignment {
+	statuses := make(map[assignmentKey]string)
+	for _, list := range lists {
+		for _, a := range list {
+			statuses[assignmentKey{courseID: a.CourseID, assignmentID: a.ID}] = a.Status
 		}
 	}
+	all := make([]EnrichedAssignment, len(assignments))
+	for i, a := range assignments {
+		a.Status = statuses[assignmentKey{courseID: a.CourseID, assignmentID: a.ID}]
+		all[i] = a
+	}
+	sortByDue(all)
+	return all
+}
+
+// looksMissing is the local heuristic for missing work: never submitted,
+// flagged missing on the submission, or graded as zero.
+func looksMissing(sub *Submission) bool {
+	unsubmitted := sub != nil && sub.SubmittedAt == nil && sub.GradedAt == nil && !sub.Excused
+	isMissing := sub == nil || sub.Missing || unsubmitted || (sub.Score != nil && *sub.Score == 0 && sub.GradedAt != nil)
+	return isMissing && !awaitingGrade(sub)
+}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].DueAt.Before(result[j].DueAt)
+func sortByDue(assignments []EnrichedAssignment) {
+	sort.Slice(assignments, func(i, j int) bool {
+		return assignments[i].DueAt.Before(assignments[j].DueAt)
 	})
+}
 
-	return result
+// windowed returns the upcoming and week-ahead sections. Upcoming starts
+// now and runs through the end of its window; the week ahead picks up the
+// day after and runs through the end of its own.
+func (r *Report) windowed(assignments []EnrichedAssignment, now time.Time) (upcoming, weekAhead []EnrichedAssignment) {
+	upcomingEnd, _ := windowEnd(r.layout.section(sectionUpcoming).Window, now)
+	weekEnd, _ := windowEnd(r.layout.section(sectionWeekAhead).Window, now)
+	upcoming = windowAssignments(assignments, now, upcomingEnd)
+	weekAhead = windowAssignments(assignments, upcomingEnd.AddDate(0, 0, 1), weekEnd)
+	return upcoming, weekAhead
 }
 
-func (r *Report) upcomingAssignments(assignments []EnrichedAssignment) []EnrichedAssignment {
-	now := time.Now()
-	today := truncateToDay(now)
-	tomorrow := nextSchoolDay(today)
+func (r *Report) printReport(w io.Writer, data studentData, colWidths columnWidths) {
+	printHeader(w, data.name)
 
-	var result []EnrichedAssignment
+	red := color.New(color.FgRed, color.Bold)
+	green := color.New(color.FgGreen, color.Bold)
+	yellow := color.New(color.FgYellow, color.Bold)
+	cyan := color.New(color.FgCyan, color.Bold)
+	dim := color.New(color.Faint)
 
-	for _, a := range assignments {
-		dueDate := truncateToDay(a.DueAt.Local())
+	printed := false
+	for _, kind := range r.sections {
+		if kind == sectionGrades {
+			if len(data.grades) > 0 {
+				if printed {
+					fmt.Fprintln(w)
+				}
+				printed = true
+				r.printGrades(w, data.grades)
+			}
+			continue
+		}
+		sec := data.section(kind)
+		// Optional sections only appear when they have something to show
+		if sec == nil || len(sec.assignments) == 0 && (sec.kind == sectionUnconfirmed || sec.kind == sectionWeekAhead) {
+			continue
+		}
+		if printed {
+			fmt.Fprintln(w)
+		}
+		printed = true
 
-		if dueDate.Equal(today) {
-			if a.DueAt.After(now) {
-				result = append(result, a)
+		switch sec.kind {
+		case sectionMissing:
+			if len(sec.assignments) == 0 {
+				green.Fprintf(w, "%s (0)\n", sec.title)
+				color.New(color.FgGreen).Fprintln(w, "  "+sec.empty)
+			} else {
+				red.Fprintf(w, "%s (%d)\n", sec.title, len(sec.assignments))
+				r.printTable(w, sec.assignments, "missing", colWidths)
+			}
+		case sectionUnconfirmed:
+			// Assignments that look missing but Canvas hasn't flagged
+			dim.Fprintf(w, "%s (%d)\n", sec.title, len(sec.assignments))
+			r.printTable(w, sec.assignments, "missing", colWidths)
+		case sectionUpcoming:
+			yellow.Fprintf(w, "%s (%d pending)\n", sec.title, sec.pending)
+			if len(sec.assignments) == 0 {
+				dim.Fprintln(w, "  "+sec.empty)
+			} else {
+				r.printTable(w, sec.assignments, "upcoming", colWidths)
 			}
-		} else if dueDate.Equal(tomorrow) {
-			result = append(result, a)
+		case sectionWeekAhead:
+			cyan.Fprintf(w, "%s (%d pending)\n", sec.title, sec.pending)
+			r.printTable(w, sec.assignments, "week_ahead", colWidths)
 		}
 	}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].DueAt.Before(result[j].DueAt)
-	})
+	if len(data.problems) > 0 {
+		if printed {
+			fmt.Fprintln(w)
+		}
+		printProblems(w, data.problems)
+	}
 
-	return result
+	r.printSummary(w, data)
 }
 
-func (r *Report) weekAheadAssignments(assignments []EnrichedAssignment) []EnrichedAssignment {
-	today := truncateToDay(time.Now())
-	tomorrow := nextSchoolDay(today)
-	weekStart := tomorrow.AddDate(0, 0, 1)
-	weekEnd := endOfSchoolWeek(today)
-
-	if weekStart.After(weekEnd) {
-		return nil
+// printSummary prints the one-line count summary for the sections shown.
+func (r *Report) printSummary(w io.Writer, data studentData) {
+	type part struct {
+		text  string
+		color *color.Color
+	}
+	var parts []part
+	if sec := data.section(sectionMissing); sec != nil {
+		parts = append(parts, part{fmt.Sprintf("%d missing", len(sec.assignments)), color.New(color.FgRed)})
+	}
+	if sec := data.section(sectionUpcoming); sec != nil {
+		parts = append(parts, part{fmt.Sprintf("%d due soon", sec.pending), color.New(color.FgYellow)})
+	}
+	if sec := data.section(sectionWeekAhead); sec != nil {
+		parts = append(parts, part{fmt.Sprintf("%d this week", sec.pending), color.New(color.FgCyan)})
+	}
+	if len(data.problems) > 0 {
+		parts = append(parts, part{fmt.Sprintf("%d data problem(s)", len(data.problems)), color.New(color.FgYellow)})
+	}
+	if len(parts) == 0 {
+		return
 	}
 
-	var result []EnrichedAssignment
-
-	for _, a := range assignments {
-		dueDate := truncateToDay(a.DueAt.Local())
-		if (dueDate.Equal(weekStart) || dueDate.After(weekStart)) && (dueDate.Equal(weekEnd) || dueDate.Before(weekEnd)) {
-			result = append(result, a)
+	fmt.Fprintln(w)
+	for i, p := range parts {
+		if i > 0 {
+			fmt.Fprint(w, " | ")
 		}
+		p.color.Fprint(w, p.text)
 	}
-
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].DueAt.Before(result[j].DueAt)
-	})
-
-	return result
+	fmt.Fprintln(w)
 }
 
-func (r *Report) printReport(data studentData, colWidths columnWidths) {
-	// Header box
+// printHeader prints the boxed student name and generation time.
+func printHeader(w io.Writer, name string) {
 	dateLine := "Generated: " + time.Now().Local().Format("Mon Jan 2, 2006 at 3:04 PM")
-	width := len(data.name)
+	width := len(name)
 	if len(dateLine) > width {
 		width = len(dateLine)
 	}
 
-	fmt.Println()
-	fmt.Println("┌" + strings.Repeat("─", width+2) + "┐")
-	fmt.Printf("│ %-*s │\n", width, data.name)
-	fmt.Printf("│ %-*s │\n", width, dateLine)
-	fmt.Println("└" + strings.Repeat("─", width+2) + "┘")
-	fmt.Println()
-
-	red := color.New(color.FgRed, color.Bold)
-	green := color.New(color.FgGreen, color.Bold)
-	yellow := color.New(color.FgYellow, color.Bold)
-	cyan := color.New(color.FgCyan, color.Bold)
-	dim := color.New(color.Faint)
-
-	// Missing section
-	if len(data.missing) == 0 {
-		green.Printf("MISSING/INCOMPLETE (0)\n")
-		color.New(color.FgGreen).Println("  All caught up!")
-	} else {
-		red.Printf("MISSING/INCOMPLETE (%d)\n", len(data.missing))
-		r.printTable(data.missing, "missing", colWidths)
-	}
-
-	// Today/Tomorrow section
-	fmt.Println()
-	yellow.Printf("DUE TODAY/TOMORROW (%d pending)\n", data.upcomingPending)
-	if len(data.upcoming) == 0 {
-		dim.Println("  Nothing due today or tomorrow.")
-	} else {
-		r.printTable(data.upcoming, "upcoming", colWidths)
-	}
-
-	// Week Ahead section (only show if there are assignments)
-	if len(data.weekAhead) > 0 {
-		fmt.Println()
-		cyan.Printf("WEEK AHEAD (%d pending)\n", data.weekAheadPending)
-		r.printTable(data.weekAhead, "week_ahead", colWidths)
-	}
-
-	// Grades section
-	r.printGrades(data.grades)
-
-	// Summary
-	fmt.Println()
-	redText := color.New(color.FgRed)
-	yellowText := color.New(color.FgYellow)
-	cyanText := color.New(color.FgCyan)
-
-	redText.Printf("%d missing", len(data.missing))
-	fmt.Print(" | ")
-	yellowText.Printf("%d due soon", data.upcomingPending)
-	fmt.Print(" | ")
-	cyanText.Printf("%d this week", data.weekAheadPending)
-	fmt.Println()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "┌"+strings.Repeat("─", width+2)+"┐")
+	fmt.Fprintf(w, "│ %-*s │\n", width, name)
+	fmt.Fprintf(w, "│ %-*s │\n", width, dateLine)
+	fmt.Fprintln(w, "└"+strings.Repeat("─", width+2)+"┘")
+	fmt.Fprintln(w)
 }
 
-func (r *Report) printTable(assignments []EnrichedAssignment, sectionType string, cw columnWidths) {
+func (r *Report) printTable(w io.Writer, assignments []EnrichedAssignment, sectionType string, cw columnWidths) {
 	widths := map[int]int{
 		0: cw.subject,
 		1: cw.assignment,
@@ -1025,7 +1394,7 @@ func (r *Report) printTable(assignments []EnrichedAssignment, sectionType string
 		5: cw.status,
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
+	table := tablewriter.NewWriter(w)
 	table.Configure(func(cfg *tablewriter.Config) {
 		cfg.Row.Formatting.AutoWrap = tw.WrapTruncate
 		cfg.Row.Alignment.PerColumn = []tw.Align{
@@ -1042,11 +1411,12 @@ func (r *Report) printTable(assignments []EnrichedAssignment, sectionType string
 
 	red := color.New(color.FgRed)
 	green := color.New(color.FgGreen)
+	yellow := color.New(color.FgYellow)
 	dim := color.New(color.Faint)
 
 	for _, a := range assignments {
-		subject := truncateString(a.CourseName, cw.subject)
-		name := formatAssignmentName(a.Name, a.CategoryName, cw.assignment, dim)
+		subject := hyperlink(truncateString(a.CourseName, cw.subject), a.CourseURL)
+		name := hyperlink(formatAssignmentName(a.Name, a.CategoryName, cw.assignment, dim), a.URL)
 		due := strings.ToLower(a.DueAt.Local().Format("Mon 1/2 3pm"))
 		pts := ""
 		if a.PointsPossible != nil {
@@ -1056,21 +1426,31 @@ func (r *Report) printTable(assignments []EnrichedAssignment, sectionType string
 		if sectionType == "missing" {
 			impact := formatImpact(a.Impact)
 			var status string
-			if a.Status == "Missing" {
+			switch {
+			case a.Status == "Missing":
 				status = red.Sprint("✗")
-			} else {
+			case a.Status == "Marked missing":
+				status = yellow.Sprint("!")
+			case a.Status == "Unconfirmed":
+				status = yellow.Sprint("?")
+			default:
 				status = red.Sprint("0")
 			}
 			table.Append(subject, name, due, pts, impact, status)
 		} else if isCompleted(a.Submission) {
-			// Don't show impact for completed assignments
+			// Don't show impact for completed assignments, only what
+			// turning them in late cost
+			status := green.Sprint("✓")
+			if submittedLate(a.Submission) {
+				status = yellow.Sprint("L")
+			}
 			table.Append(
 				dim.Sprint(subject),
 				dim.Sprint(name),
 				dim.Sprint(due),
 				dim.Sprint(pts),
-				"",
-				green.Sprint("✓"),
+				dim.Sprint(formatDeduction(a.Submission)),
+				status,
 			)
 		} else {
 			impact := formatImpact(a.Impact)
@@ -1138,7 +1518,7 @@ func formatAssignmentName(name, category string, maxWidth int, dim *color.Color)
 	return truncated
 }
 
-func (r *Report) printGrades(grades []periodGrades) {
+func (r *Report) printGrades(w io.Writer, grades []periodGrades) {
 	if len(grades) == 0 {
 		return
 	}
@@ -1146,7 +1526,11 @@ func (r *Report) printGrades(grades []periodGrades) {
 	magenta := color.New(color.FgMagenta, color.Bold)
 	dim := color.New(color.Faint)
 
-	for _, pg := range grades {
+	for i, pg := range grades {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
 		// Format period header
 		periodName := pg.period.Title
 		if periodName == "" {
@@ -1159,10 +1543,9 @@ func (r *Report) printGrades(grades []periodGrades) {
 				pg.period.EndDate.Local().Format("Jan 2"))
 		}
 
-		fmt.Println()
-		magenta.Printf("GRADES - %s%s\n", periodName, dateRange)
+		magenta.Fprintf(w, "GRADES - %s%s\n", periodName, dateRange)
 
-		table := tablewriter.NewWriter(os.Stdout)
+		table := tablewriter.NewWriter(w)
 		table.Configure(func(cfg *tablewriter.Config) {
 			cfg.Row.Formatting.AutoWrap = tw.WrapTruncate
 			cfg.Row.Alignment.PerColumn = []tw.Align{
@@ -1179,7 +1562,7 @@ func (r *Report) printGrades(grades []periodGrades) {
 			if g.Weighted {
 				// Weighted course: summary row, then indented categories
 				table.Append(
-					g.CourseName,
+					hyperlink(g.CourseName, g.URL),
 					fmt.Sprintf("%.2f%%", g.Percent),
 					"",
 					"",
@@ -1197,7 +1580,7 @@ func (r *Report) printGrades(grades []periodGrades) {
 			} else {
 				// Non-weighted course: simple row
 				table.Append(
-					g.CourseName,
+					hyperlink(g.CourseName, g.URL),
 					fmt.Sprintf("%.2f%%", g.Percent),
 					fmt.Sprintf("%.0f", g.Points),
 					fmt.Sprintf("%.0f", g.PointsPossible),
@@ -0,0 +1,421 @@
+// ABOUTME: Token storage for canvas-report.
+// ABOUTME: Keeps Canvas credentials out of config.yaml via a passphrase-encrypted keystore or a git-style credential helper.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Token storage modes for Config.TokenStorage.
+const (
+	storagePlaintext  = "plaintext"
+	storagePassphrase = "passphrase"
+	storageHelper     = "helper"
+)
+
+var tokenStorageModes = []string{storagePlaintext, storagePassphrase, storageHelper}
+
+const envPassphrase = "CANVAS_REPORT_PASSPHRASE"
+
+// secretField is one secret value in the config, such as a profile's access
+// token, along with what a store needs to find it.
+type secretField struct {
+	profile string
+	name    string
+	host    string  // Canvas host, for credential helpers
+	value   *string // The in-memory value on the Config
+}
+
+func (f secretField) key() string {
+	return f.profile + "/" + f.name
+}
+
+// secretStore saves and retrieves secrets outside the config file. Get
+// returns an empty string for a secret that was never stored.
+type secretStore interface {
+	Get(f secretField) (string, error)
+	Set(f secretField, value string) error
+}
+
+// newSecretStore returns the store for the config's token_storage mode, or
+// nil for plaintext.
+func newSecretStore(cfg *Config, configPath string) (secretStore, error) {
+	switch cfg.TokenStorage {
+	case "", storagePlaintext:
+		return nil, nil
+	case storagePassphrase:
+		return &keystore{path: filepath.Join(filepath.Dir(configPath), "keystore.json")}, nil
+	case storageHelper:
+		if strings.TrimSpace(cfg.CredentialHelper) == "" {
+			return nil, fmt.Errorf("token_storage is %q but credential_helper is not set", storageHelper)
+		}
+		return &credentialHelper{command: strings.Fields(cfg.CredentialHelper)}, nil
+	default:
+		return nil, fmt.Errorf("unknown token_storage %q (want %s)", cfg.TokenStorage, strings.Join(tokenStorageModes, ", "))
+	}
+}
+
+// keystore is a local JSON file of AES-GCM encrypted secrets, keyed by a
+// PBKDF2 derivation of the user's passphrase.
+type keystore struct {
+	path string
+	key  []byte // Derived key, cached after the first unlock
+	file *keystoreFile
+}
+
+type keystoreFile struct {
+	Version    int                      `json:"version"`
+	KDF        string                   `json:"kdf"`
+	Iterations int                      `json:"iterations"`
+	Salt       []byte                   `json:"salt"`
+	Check      *sealedSecret            `json:"check"` // Encrypts a known value to detect a wrong passphrase
+	Secrets    map[string]*sealedSecret `json:"secrets"`
+}
+
+type sealedSecret struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+const (
+	keystoreIterations = 600_000
+	keystoreCheckValue = "canvas-report"
+)
+
+func (k *keystore) Get(f secretField) (string, error) {
+	if _, err := os.Stat(k.path); os.IsNotExist(err) && k.file == nil {
+		return "", nil
+	}
+	if err := k.unlock(); err != nil {
+		return "", err
+	}
+	sealed, ok := k.file.Secrets[f.key()]
+	if !ok {
+		return "", nil
+	}
+	return k.open(sealed)
+}
+
+func (k *keystore) Set(f secretField, value string) error {
+	if err := k.unlock(); err != nil {
+		return err
+	}
+	sealed, err := k.seal(value)
+	if err != nil {
+		return err
+	}
+	k.file.Secrets[f.key()] = sealed
+	return k.save()
+}
+
+// unlock loads the keystore, creating it if needed, and derives the key,
+// prompting for the passphrase once.
+func (k *keystore) unlock() error {
+	if k.key != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(k.path)
+	switch {
+	case err == nil:
+		var file keystoreFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("reading %s: %w", k.path, err)
+		}
+		if file.Secrets == nil {
+			file.Secrets = make(map[string]*sealedSecret)
+		}
+		k.file = &file
+	case os.IsNotExist(err):
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		k.file = &keystoreFile{
+			Version:    1,
+			KDF:        "pbkdf2-sha256",
+			Iterations: keystoreIterations,
+			Salt:       salt,
+			Secrets:    make(map[string]*sealedSecret),
+		}
+	default:
+		return err
+	}
+
+	isNew := k.file.Check == nil
+	passphrase, err := readPassphrase(isNew)
+	if err != nil {
+		return err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, k.file.Salt, k.file.Iterations, 32)
+	if err != nil {
+		return err
+	}
+	k.key = key
+
+	if isNew {
+		check, err := k.seal(keystoreCheckValue)
+		if err != nil {
+			return err
+		}
+		k.file.Check = check
+		return nil
+	}
+	if v, err := k.open(k.file.Check); err != nil || v != keystoreCheckValue {
+		k.key = nil
+		return errors.New("wrong passphrase")
+	}
+	return nil
+}
+
+func (k *keystore) seal(value string) (*sealedSecret, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &sealedSecret{Nonce: nonce, Ciphertext: gcm.Seal(nil, nonce, []byte(value), nil)}, nil
+}
+
+func (k *keystore) open(sealed *sealedSecret) (string, error) {
+	gcm, err := k.gcm()
+	if err != nil {
+		return "", err
+	}
+	plain, err := gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, nil)
+	if err != nil {
+		return "", errors.New("could not decrypt secret (wrong passphrase or corrupted keystore)")
+	}
+	return string(plain), nil
+}
+
+func (k *keystore) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *keystore) save() error {
+	data, err := json.MarshalIndent(k.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(k.path, data, 0600)
+}
+
+// readPassphrase takes the passphrase from the environment or, failing
+// that, prompts on the terminal. New passphrases are asked for twice.
+func readPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv(envPassphrase); p != "" {
+		return p, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("a passphrase is needed for the token keystore; set %s when not running in a terminal", envPassphrase)
+	}
+
+	fmt.Fprint(os.Stderr, "Keystore passphrase: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if !confirm {
+		return string(first), nil
+	}
+	if len(first) == 0 {
+		return "", errors.New("passphrase can't be empty")
+	}
+
+	fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(first, second) {
+		return "", errors.New("passphrases don't match")
+	}
+	return string(first), nil
+}
+
+// credentialHelper delegates storage to an external command speaking git's
+// credential helper protocol, e.g. "git credential-osxkeychain" or
+// "git-credential-libsecret".
+type credentialHelper struct {
+	command []string
+}
+
+func (h *credentialHelper) Get(f secretField) (string, error) {
+	out, err := h.run("get", f, "")
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "password="); ok {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+func (h *credentialHelper) Set(f secretField, value string) error {
+	_, err := h.run("store", f, value)
+	return err
+}
+
+func (h *credentialHelper) run(action string, f secretField, password string) ([]byte, error) {
+	var in strings.Builder
+	in.WriteString("protocol=https\n")
+	fmt.Fprintf(&in, "host=%s\n", f.host)
+	fmt.Fprintf(&in, "username=canvas-report:%s\n", f.key())
+	if password != "" {
+		fmt.Fprintf(&in, "password=%s\n", password)
+	}
+	in.WriteString("\n")
+
+	cmd := exec.Command(h.command[0], append(h.command[1:], action)...)
+	cmd.Stdin = strings.NewReader(in.String())
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %s: %w", action, err)
+	}
+	return out, nil
+}
+
+// secretFields lists every secret in the config.
+func (c *Config) secretFields() []secretField {
+	var fields []secretField
+	for _, name := range c.ProfileNames() {
+		p, _ := c.GetProfile(name)
+		for _, s := range []struct {
+			name  string
+			value *string
+		}{
+			{"access_token", &p.AccessToken},
+			{"refresh_token", &p.RefreshToken},
+			{"client_secret", &p.ClientSecret},
+		} {
+			fields = append(fields, secretField{
+				profile: name,
+				name:    s.name,
+				host:    hostOf(p.BaseURL),
+				value:   s.value,
+			})
+		}
+	}
+	if c.Email.SMTP.Host != "" {
+		fields = append(fields, secretField{
+			profile: "email",
+			name:    "smtp_password",
+			host:    c.Email.SMTP.Host,
+			value:   &c.Email.SMTP.Password,
+		})
+	}
+	return fields
+}
+
+func hostOf(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
+
+// loadSecrets fills in secrets from the store. Plaintext secrets still in the
+// file are moved into the store; it reports whether that happened so the
+// caller can rewrite the file without them.
+func (c *Config) loadSecrets() (migrated bool, err error) {
+	if c.secrets == nil {
+		return false, nil
+	}
+	c.stored = make(map[string]string)
+
+	for _, f := range c.secretFields() {
+		if *f.value != "" {
+			// Left in the file by an older version or a hand edit
+			migrated = true
+			continue
+		}
+		value, err := c.secrets.Get(f)
+		if err != nil {
+			return false, fmt.Errorf("%s for profile %q: %w", f.name, f.profile, err)
+		}
+		*f.value = value
+		c.stored[f.key()] = value
+	}
+	return migrated, nil
+}
+
+// storeSecrets writes changed secrets to the store and returns a copy of the
+// config with them removed, ready to be written to disk.
+func (c *Config) storeSecrets() (*Config, error) {
+	if c.secrets == nil {
+		return c, nil
+	}
+
+	onDisk := c.clone()
+	// Listed before any are cleared: a default profile holding nothing but
+	// secrets drops out of the list once they're gone
+	diskFields := onDisk.secretFields()
+	for _, f := range c.secretFields() {
+		if *f.value != "" && c.stored[f.key()] != *f.value {
+			if err := c.secrets.Set(f, *f.value); err != nil {
+				return nil, fmt.Errorf("storing %s for profile %q: %w", f.name, f.profile, err)
+			}
+			if c.stored == nil {
+				c.stored = make(map[string]string)
+			}
+			c.stored[f.key()] = *f.value
+		}
+	}
+	for _, f := range diskFields {
+		*f.value = ""
+	}
+	return onDisk, nil
+}
+
+// clone returns a copy of the config whose profiles can be modified without
+// affecting c.
+func (c *Config) clone() *Config {
+	dup := *c
+	if c.Profiles != nil {
+		dup.Profiles = make(map[string]*Profile, len(c.Profiles))
+		for name, p := range c.Profiles {
+			pc := *p
+			dup.Profiles[name] = &pc
+		}
+	}
+	return &dup
+}
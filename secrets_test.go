@@ -0,0 +1,135 @@
+// ABOUTME: Tests for canvas-report token storage.
+// ABOUTME: Round-trips secrets through the passphrase keystore and checks plaintext tokens migrate out of the config file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeystoreSealOpen(t *testing.T) {
+	t.Setenv(envPassphrase, "correct horse")
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	field := secretField{profile: defaultProfile, name: "access_token"}
+
+	k := &keystore{path: path}
+	if got, err := k.Get(field); err != nil || got != "" {
+		t.Fatalf("Get before the keystore exists = %q, %v; want empty", got, err)
+	}
+	if err := k.Set(field, "tok-123"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "tok-123") {
+		t.Error("keystore file contains the secret in plaintext")
+	}
+
+	reopened := &keystore{path: path}
+	got, err := reopened.Get(field)
+	if err != nil || got != "tok-123" {
+		t.Fatalf("Get after reopening = %q, %v; want tok-123", got, err)
+	}
+	if got, err := reopened.Get(secretField{profile: "work", name: "access_token"}); err != nil || got != "" {
+		t.Errorf("Get of a secret never stored = %q, %v; want empty", got, err)
+	}
+
+	t.Setenv(envPassphrase, "wrong")
+	if _, err := (&keystore{path: path}).Get(field); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("Get with the wrong passphrase: err = %v, want wrong passphrase", err)
+	}
+}
+
+func TestSecretsMigrateOutOfConfig(t *testing.T) {
+	t.Setenv(envPassphrase, "correct horse")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	plain := `base_url: https://school.instructure.com
+access_token: default-tok
+token_storage: passphrase
+profiles:
+  work:
+    base_url: https://work.instructure.com
+    access_token: work-tok
+    refresh_token: work-refresh
+email:
+  smtp:
+    host: smtp.example.com
+    password: smtp-pw
+`
+	if err := os.WriteFile(path, []byte(plain), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"default/access_token": "default-tok",
+		"work/access_token":    "work-tok",
+		"work/refresh_token":   "work-refresh",
+		"email/smtp_password":  "smtp-pw",
+	}
+	checkSecrets(t, "after migrating", cfg, want)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range want {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("config file still contains %q after migrating:\n%s", secret, data)
+		}
+	}
+	for _, u := range []string{"https://school.instructure.com", "https://work.instructure.com"} {
+		if !strings.Contains(string(data), u) {
+			t.Errorf("config file lost base_url %s:\n%s", u, data)
+		}
+	}
+
+	reloaded, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSecrets(t, "after reloading", reloaded, want)
+
+	// A changed token is stored again and still kept out of the file
+	reloaded.Profiles["work"].AccessToken = "work-tok-2"
+	if err := saveConfig(path, reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Profiles["work"].AccessToken != "work-tok-2" {
+		t.Error("saving cleared the in-memory token")
+	}
+	final, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want["work/access_token"] = "work-tok-2"
+	checkSecrets(t, "after changing a token", final, want)
+}
+
+func checkSecrets(t *testing.T, when string, cfg *Config, want map[string]string) {
+	t.Helper()
+	got := make(map[string]string)
+	for _, f := range cfg.secretFields() {
+		if *f.value != "" {
+			got[f.key()] = *f.value
+		}
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: %s = %q, want %q", when, key, got[key], value)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("%s: got secrets %v, want %v", when, got, want)
+	}
+}
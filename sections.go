@@ -0,0 +1,231 @@
+// ABOUTME: Configurable report layout for canvas-report.
+// ABOUTME: Parses which sections the report shows, their order, titles, time windows, sort keys and the missing cutoff.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportConfig is the report section of the config file.
+type ReportConfig struct {
+	MissingCutoff string          `yaml:"missing_cutoff,omitempty"` // How far back missing work goes, like 30d or 6w (default 30d)
+	Sections      []SectionConfig `yaml:"sections,omitempty"`       // Which sections the report shows, in order; default all
+}
+
+// SectionConfig customizes one report section.
+type SectionConfig struct {
+	Kind   string `yaml:"kind"`
+	Title  string `yaml:"title,omitempty"`
+	Window string `yaml:"window,omitempty"` // upcoming and week_ahead only
+	Sort   string `yaml:"sort,omitempty"`   // due (default), impact, points or course
+}
+
+const (
+	defaultMissingCutoff = 30 * 24 * time.Hour
+	defaultUpcomingSpan  = "1 school day"
+	defaultWeekAheadSpan = "school week"
+)
+
+var sectionSorts = []string{"due", "impact", "points", "course"}
+
+var windowPattern = regexp.MustCompile(`^(\d+) (school days?|days?|weeks?)$`)
+
+var cutoffPattern = regexp.MustCompile(`^(\d+)([dw])$`)
+
+func (rc ReportConfig) validate() error {
+	if _, err := rc.cutoff(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, sc := range rc.Sections {
+		if !containsString(allSections, sc.Kind) {
+			return fmt.Errorf("report section %q: unknown kind (want %s)", sc.Kind, strings.Join(allSections, ", "))
+		}
+		if seen[sc.Kind] {
+			return fmt.Errorf("report section %q appears twice", sc.Kind)
+		}
+		seen[sc.Kind] = true
+		if sc.Window != "" {
+			if sc.Kind != sectionUpcoming && sc.Kind != sectionWeekAhead {
+				return fmt.Errorf("report section %q: only upcoming and week_ahead take a window", sc.Kind)
+			}
+			if _, err := windowEnd(sc.Window, time.Now()); err != nil {
+				return fmt.Errorf("report section %q: %w", sc.Kind, err)
+			}
+		}
+		if sc.Kind == sectionGrades && (sc.Title != "" || sc.Sort != "") {
+			return fmt.Errorf("report section %q: only its position can be set", sc.Kind)
+		}
+		if sc.Sort != "" {
+			if !containsString(sectionSorts, sc.Sort) {
+				return fmt.Errorf("report section %q: unknown sort %q (want %s)", sc.Kind, sc.Sort, strings.Join(sectionSorts, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// cutoff is how old missing work can be and still be shown.
+func (rc ReportConfig) cutoff() (time.Duration, error) {
+	if rc.MissingCutoff == "" {
+		return defaultMissingCutoff, nil
+	}
+	m := cutoffPattern.FindStringSubmatch(strings.TrimSpace(rc.MissingCutoff))
+	if m == nil {
+		return 0, fmt.Errorf("report missing_cutoff %q: want a number of days or weeks, like 30d or 6w", rc.MissingCutoff)
+	}
+	n, _ := strconv.Atoi(m[1])
+	if m[2] == "w" {
+		n *= 7
+	}
+	return time.Duration(n) * 24 * time.Hour, nil
+}
+
+// order returns the section kinds the report shows. Commands that name
+// their sections keep them all, in the configured order where it's given;
+// a nil list means the full report as configured.
+func (rc ReportConfig) order(requested []string) []string {
+	if len(rc.Sections) == 0 {
+		if requested == nil {
+			return allSections
+		}
+		return requested
+	}
+	var kinds []string
+	for _, sc := range rc.Sections {
+		if requested == nil || containsString(requested, sc.Kind) {
+			kinds = append(kinds, sc.Kind)
+		}
+	}
+	for _, kind := range requested {
+		if !containsString(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// section returns the configuration for a kind, with defaults filled in.
+func (rc ReportConfig) section(kind string) SectionConfig {
+	sc := SectionConfig{Kind: kind}
+	for _, c := range rc.Sections {
+		if c.Kind == kind {
+			sc = c
+		}
+	}
+	sc.Window = strings.ToLower(strings.TrimSpace(sc.Window))
+	switch {
+	case kind == sectionUpcoming && sc.Window == "":
+		sc.Window = defaultUpcomingSpan
+	case kind == sectionWeekAhead && sc.Window == "":
+		sc.Window = defaultWeekAheadSpan
+	case kind == sectionUpcoming && sc.Title == "" && sc.Window != defaultUpcomingSpan:
+		// The stock title would describe the wrong days. The week ahead
+		// starts where upcoming ends, so its window alone can't name it.
+		sc.Title = "DUE IN THE NEXT " + strings.ToUpper(sc.Window)
+	}
+	if sc.Title == "" {
+		sc.Title = sectionTitles[kind]
+	}
+	if sc.Sort == "" {
+		sc.Sort = "due"
+	}
+	return sc
+}
+
+// windowEnd returns the last day a window covers, counting from today:
+// "N school days", "N days", "N weeks" or "school week".
+func windowEnd(window string, now time.Time) (time.Time, error) {
+	today := truncateToDay(now)
+	window = strings.ToLower(strings.TrimSpace(window))
+	if window == "school week" {
+		return endOfSchoolWeek(today), nil
+	}
+	m := windowPattern.FindStringSubmatch(window)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("window %q: want \"N school days\", \"N days\", \"N weeks\" or \"school week\"", window)
+	}
+	n, _ := strconv.Atoi(m[1])
+	switch {
+	case strings.HasPrefix(m[2], "school"):
+		end := today
+		for range n {
+			end = nextSchoolDay(end)
+		}
+		return end, nil
+	case strings.HasPrefix(m[2], "week"):
+		return today.AddDate(0, 0, 7*n), nil
+	}
+	return today.AddDate(0, 0, n), nil
+}
+
+// windowAssignments returns the assignments due from start through the
+// end of the day last.
+func windowAssignments(assignments []EnrichedAssignment, start, last time.Time) []EnrichedAssignment {
+	end := last.AddDate(0, 0, 1)
+	var result []EnrichedAssignment
+	for _, a := range assignments {
+		if !a.DueAt.Before(start) && a.DueAt.Before(end) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// empty is what the section says when it has nothing in it.
+func (sc SectionConfig) empty() string {
+	switch sc.Kind {
+	case sectionMissing:
+		return "All caught up!"
+	case sectionUpcoming:
+		if sc.Window != defaultUpcomingSpan {
+			return "Nothing due in the next " + sc.Window + "."
+		}
+		return "Nothing due today or tomorrow."
+	}
+	return "Nothing due."
+}
+
+// sortAssignments orders assignments by due date, then by key: the
+// biggest possible gain first, the most points first, or by course.
+func sortAssignments(assignments []EnrichedAssignment, key string) {
+	sort.SliceStable(assignments, func(i, j int) bool {
+		return assignments[i].DueAt.Before(assignments[j].DueAt)
+	})
+	sort.SliceStable(assignments, func(i, j int) bool {
+		a, b := assignments[i], assignments[j]
+		switch key {
+		case "impact":
+			return pendingGain(a) > pendingGain(b)
+		case "points":
+			return pointsOrNone(a) > pointsOrNone(b)
+		case "course":
+			return a.CourseName < b.CourseName
+		}
+		return false
+	})
+}
+
+// pendingGain is what full credit would add, or -1 for work already
+// turned in or without an impact.
+func pendingGain(a EnrichedAssignment) float64 {
+	if a.Impact == nil || isCompleted(a.Submission) {
+		return -1
+	}
+	return a.Impact.Gain
+}
+
+// pointsOrNone returns an assignment's points possible, or -1 when it has
+// none so it sorts after work worth zero points.
+func pointsOrNone(a EnrichedAssignment) float64 {
+	if a.PointsPossible == nil {
+		return -1
+	}
+	return *a.PointsPossible
+}
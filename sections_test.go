@@ -0,0 +1,186 @@
+// ABOUTME: Tests for the configurable report layout.
+// ABOUTME: Covers section windows across weekends and daylight saving changes, which assignments each window picks up, and section titles.
+
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestWindowEnd(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, ny)
+	}
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, ny)
+	}
+
+	tests := []struct {
+		name   string
+		window string
+		now    time.Time
+		want   time.Time
+	}{
+		{"next school day midweek", "1 school day", at(2026, time.October, 14, 9), day(2026, time.October, 15)},
+		{"next school day from Friday", "1 school day", at(2026, time.October, 16, 15), day(2026, time.October, 19)},
+		{"next school day from Saturday", "1 school day", at(2026, time.October, 17, 10), day(2026, time.October, 19)},
+		{"school days skip the weekend", "3 school days", at(2026, time.October, 15, 9), day(2026, time.October, 20)},
+		{"school week midweek", "school week", at(2026, time.October, 14, 9), day(2026, time.October, 16)},
+		{"school week from Friday", "school week", at(2026, time.October, 16, 9), day(2026, time.October, 23)},
+		{"school week from Sunday", "School Week", at(2026, time.October, 18, 9), day(2026, time.October, 23)},
+		{"calendar days", "3 days", at(2026, time.October, 14, 23), day(2026, time.October, 17)},
+		{"zero days is today", "0 days", at(2026, time.October, 14, 23), day(2026, time.October, 14)},
+		{"one day, singular", "1 day", at(2026, time.October, 14, 9), day(2026, time.October, 15)},
+		{"weeks", "2 weeks", at(2026, time.October, 14, 9), day(2026, time.October, 28)},
+		{"trimmed and lowercased", "  2 Days ", at(2026, time.October, 14, 9), day(2026, time.October, 16)},
+
+		// Clocks spring forward at 2am on Sunday March 8, 2026 and fall
+		// back at 2am on Sunday November 1, 2026
+		{"school day across spring forward", "1 school day", at(2026, time.March, 6, 15), day(2026, time.March, 9)},
+		{"days across spring forward", "3 days", at(2026, time.March, 6, 23), day(2026, time.March, 9)},
+		{"week across spring forward", "1 week", at(2026, time.March, 6, 15), day(2026, time.March, 13)},
+		{"school week across spring forward", "school week", at(2026, time.March, 7, 12), day(2026, time.March, 13)},
+		{"onto fall back day", "1 day", at(2026, time.October, 31, 10), day(2026, time.November, 1)},
+		{"days across fall back", "2 days", at(2026, time.October, 31, 23), day(2026, time.November, 2)},
+		{"school day across fall back", "1 school day", at(2026, time.October, 30, 15), day(2026, time.November, 2)},
+	}
+	for _, tt := range tests {
+		got, err := windowEnd(tt.window, tt.now)
+		if err != nil {
+			t.Errorf("%s: windowEnd(%q): %v", tt.name, tt.window, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: windowEnd(%q, %s) = %s, want %s", tt.name, tt.window, tt.now, got, tt.want)
+		}
+		if got.Hour() != 0 || got.Minute() != 0 {
+			t.Errorf("%s: windowEnd(%q) = %s, want local midnight", tt.name, tt.window, got)
+		}
+	}
+}
+
+func TestWindowEndErrors(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 9, 0, 0, 0, time.UTC)
+	for _, window := range []string{"", "fortnight", "3 months", "-1 days", "two days", "3", "school weeks"} {
+		if got, err := windowEnd(window, now); err == nil {
+			t.Errorf("windowEnd(%q) = %s, want an error", window, got)
+		}
+	}
+}
+
+func TestWindowed(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, ny)
+	}
+	assignments := func(dues ...time.Time) []EnrichedAssignment {
+		var list []EnrichedAssignment
+		for _, due := range dues {
+			list = append(list, EnrichedAssignment{Name: due.Format("Mon 1/2 15:04"), DueAt: due})
+		}
+		return list
+	}
+	names := func(list []EnrichedAssignment) []string {
+		out := []string{}
+		for _, a := range list {
+			out = append(out, a.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name          string
+		layout        ReportConfig
+		now           time.Time
+		due           []time.Time
+		wantUpcoming  []string
+		wantWeekAhead []string
+	}{
+		{
+			// Friday afternoon before spring forward: upcoming runs through
+			// Monday, the week ahead Tuesday through Friday
+			name: "default windows across spring forward",
+			now:  at(time.March, 6, 15, 0),
+			due: []time.Time{
+				at(time.March, 6, 14, 0),  // Already past
+				at(time.March, 6, 23, 59), // Tonight
+				at(time.March, 8, 12, 0),  // Sunday
+				at(time.March, 9, 23, 30), // Last minutes of Monday
+				at(time.March, 10, 0, 0),  // Tuesday midnight
+				at(time.March, 13, 23, 59),
+				at(time.March, 14, 9, 0), // Saturday, past both windows
+			},
+			wantUpcoming:  []string{"Fri 3/6 23:59", "Sun 3/8 12:00", "Mon 3/9 23:30"},
+			wantWeekAhead: []string{"Tue 3/10 00:00", "Fri 3/13 23:59"},
+		},
+		{
+			name: "custom windows across fall back",
+			layout: ReportConfig{Sections: []SectionConfig{
+				{Kind: sectionUpcoming, Window: "2 days"},
+				{Kind: sectionWeekAhead, Window: "1 week"},
+			}},
+			now: at(time.October, 31, 10, 0),
+			due: []time.Time{
+				at(time.November, 1, 1, 30), // The hour that happens twice
+				at(time.November, 2, 23, 59),
+				at(time.November, 3, 0, 0),
+				at(time.November, 7, 23, 59),
+				at(time.November, 8, 0, 0),
+			},
+			wantUpcoming:  []string{"Sun 11/1 01:30", "Mon 11/2 23:59"},
+			wantWeekAhead: []string{"Tue 11/3 00:00", "Sat 11/7 23:59"},
+		},
+		{
+			name: "week ahead shorter than upcoming is empty",
+			layout: ReportConfig{Sections: []SectionConfig{
+				{Kind: sectionUpcoming, Window: "5 school days"},
+			}},
+			now:           at(time.October, 14, 9, 0), // Wednesday
+			due:           []time.Time{at(time.October, 16, 12, 0), at(time.October, 21, 12, 0), at(time.October, 22, 12, 0)},
+			wantUpcoming:  []string{"Fri 10/16 12:00", "Wed 10/21 12:00"},
+			wantWeekAhead: []string{},
+		},
+	}
+	for _, tt := range tests {
+		r := &Report{layout: tt.layout}
+		upcoming, weekAhead := r.windowed(assignments(tt.due...), tt.now)
+		if got := names(upcoming); !slices.Equal(got, tt.wantUpcoming) {
+			t.Errorf("%s: upcoming = %q, want %q", tt.name, got, tt.wantUpcoming)
+		}
+		if got := names(weekAhead); !slices.Equal(got, tt.wantWeekAhead) {
+			t.Errorf("%s: week ahead = %q, want %q", tt.name, got, tt.wantWeekAhead)
+		}
+	}
+}
+
+func TestSectionTitles(t *testing.T) {
+	tests := []struct {
+		name    string
+		section SectionConfig
+		want    string
+	}{
+		{"upcoming default", SectionConfig{Kind: sectionUpcoming}, sectionTitles[sectionUpcoming]},
+		{"upcoming custom window", SectionConfig{Kind: sectionUpcoming, Window: "3 School Days"}, "DUE IN THE NEXT 3 SCHOOL DAYS"},
+		{"upcoming custom title", SectionConfig{Kind: sectionUpcoming, Window: "3 school days", Title: "SOON"}, "SOON"},
+		{"week ahead custom window", SectionConfig{Kind: sectionWeekAhead, Window: "2 weeks"}, sectionTitles[sectionWeekAhead]},
+		{"week ahead custom title", SectionConfig{Kind: sectionWeekAhead, Window: "2 weeks", Title: "LATER"}, "LATER"},
+	}
+	for _, tt := range tests {
+		rc := ReportConfig{Sections: []SectionConfig{tt.section}}
+		if got := rc.section(tt.section.Kind).Title; got != tt.want {
+			t.Errorf("%s: title = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
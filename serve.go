@@ -0,0 +1,417 @@
+// ABOUTME: Local web dashboard for canvas-report.
+// ABOUTME: Serves the report as web pages and a JSON API from a snapshot refreshed in the background.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultServeAddr    = "localhost:8080"
+	defaultServeRefresh = 15 * time.Minute
+)
+
+const envServePassword = "CANVAS_REPORT_SERVE_PASSWORD"
+
+func serveFlags(c *cli, fs *flag.FlagSet) {
+	fs.StringVar(&c.serveAddr, "addr", defaultServeAddr, "`address` to listen on; :8080 allows other devices on the network, so pair it with --password")
+	fs.StringVar(&c.servePassword, "password", "", "require this `password` (with any user name) to view the dashboard; also "+envServePassword)
+	fs.DurationVar(&c.serveRefresh, "refresh", defaultServeRefresh, "how often to fetch fresh data from Canvas")
+	allFlag(c, fs)
+}
+
+// snapshot is the most recent report data the server has.
+type snapshot struct {
+	students  []studentData
+	slugs     []string // URL name for each student, in the same order
+	fetched   time.Time
+	err       error // The last refresh's error, if it failed; students are then older
+	errorTime time.Time
+}
+
+// dashboard serves one snapshot, replacing it after each refresh.
+type dashboard struct {
+	reports []*Report
+	every   time.Duration
+
+	mu   sync.RWMutex
+	snap snapshot
+}
+
+func runServe(c *cli, args []string) error {
+	reports, err := c.loadReports(args, nil)
+	if err != nil {
+		return err
+	}
+	if c.serveRefresh < time.Minute {
+		return fmt.Errorf("--refresh must be at least 1m, to go easy on Canvas")
+	}
+	for _, r := range reports {
+		r.quiet = true
+	}
+
+	d := &dashboard{reports: reports, every: c.serveRefresh}
+	fmt.Fprintln(os.Stderr, "Fetching report data...")
+	if err := d.refresh(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go d.refreshEvery(ctx)
+
+	handler := d.routes()
+	if password := flagOrEnv(c.servePassword, envServePassword); password != "" {
+		handler = requirePassword(handler, password)
+	} else if !isLoopback(c.serveAddr) {
+		fmt.Fprintf(os.Stderr, "warning: %s is reachable from other devices and has no password; set --password or %s\n", c.serveAddr, envServePassword)
+	}
+
+	server := &http.Server{Addr: c.serveAddr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving the report on http://%s (refreshing every %s; Ctrl-C to stop)\n", c.serveAddr, formatInterval(c.serveRefresh))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// isLoopback reports whether addr only accepts connections from this
+// computer.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requirePassword wraps next in HTTP basic auth. Only the password is
+// checked; browsers just need something in the user name box.
+func requirePassword(next http.Handler, password string) http.Handler {
+	want := sha256.Sum256([]byte(password))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, given, ok := r.BasicAuth()
+		got := sha256.Sum256([]byte(given))
+		if !ok || subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="canvas-report", charset="UTF-8"`)
+			http.Error(w, "password required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// refresh collects fresh data. On failure the previous students are kept
+// and the error is shown on every page until a refresh succeeds.
+func (d *dashboard) refresh() error {
+	var students []studentData
+	var err error
+	for _, r := range d.reports {
+		var collected []studentData
+		if collected, err = r.Collect(); err != nil {
+			break
+		}
+		students = append(students, collected...)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.snap.err, d.snap.errorTime = err, time.Now()
+		return err
+	}
+	d.snap = snapshot{students: students, slugs: studentSlugs(students), fetched: time.Now()}
+	return nil
+}
+
+func (d *dashboard) refreshEvery(ctx context.Context) {
+	ticker := time.NewTicker(d.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.refresh(); err != nil {
+				fmt.Fprintf(os.Stderr, "Refresh failed: %v\n", err)
+			}
+		}
+	}
+}
+
+func (d *dashboard) current() snapshot {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.snap
+}
+
+func (d *dashboard) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.handleIndex)
+	mux.HandleFunc("GET /students/{slug}", d.handleStudent)
+	mux.HandleFunc("GET /api/report", d.handleAPIReport)
+	mux.HandleFunc("GET /api/status", d.handleAPIStatus)
+	mux.HandleFunc("GET /api/students/{slug}", d.handleAPIStudent)
+	return mux
+}
+
+// studentSlugs names each student in URLs, e.g. "jane-doe", numbering
+// repeats.
+func studentSlugs(students []studentData) []string {
+	slugs := make([]string, len(students))
+	seen := make(map[string]int)
+	for i, s := range students {
+		slug := strings.Trim(strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+				return r
+			case r >= 'A' && r <= 'Z':
+				return r + 'a' - 'A'
+			default:
+				return '-'
+			}
+		}, s.name), "-")
+		if slug == "" {
+			slug = "student"
+		}
+		seen[slug]++
+		if n := seen[slug]; n > 1 {
+			slug = fmt.Sprintf("%s-%d", slug, n)
+		}
+		slugs[i] = slug
+	}
+	return slugs
+}
+
+func (s snapshot) find(slug string) (studentData, bool) {
+	for i, candidate := range s.slugs {
+		if candidate == slug {
+			return s.students[i], true
+		}
+	}
+	return studentData{}, false
+}
+
+// servePage is the data for the dashboard page template.
+type servePage struct {
+	Report    htmlData
+	Title     string
+	Nav       []serveLink
+	Statuses  []serveLink // Index only: each student's status line
+	Calendar  []calendarDay
+	Workload  []workloadHTML
+	Updated   string
+	Every     string
+	Error     string
+	ErrorTime string
+}
+
+type serveLink struct {
+	Text, URL string
+	Current   bool
+}
+
+// calendarDay is one school day in the week calendar.
+type calendarDay struct {
+	Label string
+	Today bool
+	Items []calendarItem
+}
+
+type calendarItem struct {
+	Student, Course, Name, URL string
+	Done                       bool
+}
+
+var servePageTemplate = template.Must(template.Must(htmlTemplate.Clone()).Parse(`{{define "page"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="300">
+<title>{{.Title}}</title></head>
+<body style="{{style .Report.Body}};max-width:960px;margin:0 auto;padding:8px">
+<nav style="margin:8px 0">{{range $i, $l := .Nav}}{{if $i}} · {{end}}{{if $l.Current}}<strong>{{$l.Text}}</strong>{{else}}<a href="{{$l.URL}}">{{$l.Text}}</a>{{end}}{{end}}</nav>
+<p style="{{style .Report.Dim}};margin:0">Updated {{.Updated}}, refreshed every {{.Every}}</p>
+{{if .Error}}<p style="color:#c0392b"><strong>The last refresh failed ({{.ErrorTime}}):</strong> {{.Error}}</p>{{end}}
+{{if .Statuses}}<ul style="font-size:16px">{{range .Statuses}}<li><a href="{{.URL}}">{{.Text}}</a></li>{{end}}</ul>{{end}}
+{{if .Calendar}}<h3 style="margin:16px 0 4px 0">THIS WEEK</h3>
+{{range .Calendar}}<div style="margin:6px 0"><strong{{if .Today}} style="color:#b7950b"{{end}}>{{.Label}}</strong>
+{{if .Items}}<ul style="margin:2px 0">{{range .Items}}<li{{if .Done}} style="{{style $.Report.Dim}}"{{end}}>{{if .Student}}{{.Student}}: {{end}}{{.Course}} - <a href="{{.URL}}" style="{{style $.Report.Link}}">{{.Name}}</a>{{if .Done}} ✓{{end}}</li>{{end}}</ul>{{else}}<div style="{{style $.Report.Dim}}">Nothing due</div>{{end}}</div>
+{{end}}{{end}}
+{{if .Workload}}<h3 style="margin:16px 0 4px 0">WORKLOAD - NEXT {{len (index .Workload 0).Weeks}} WEEKS</h3>
+{{range .Workload}}{{if .Name}}<div style="margin:8px 0 0 0"><strong>{{.Name}}</strong></div>{{end}}
+<table style="{{style $.Report.Table}}">
+<tr><th style="{{style $.Report.Head}}">Week</th><th style="{{style $.Report.Head}}">Mon</th><th style="{{style $.Report.Head}}">Tue</th><th style="{{style $.Report.Head}}">Wed</th><th style="{{style $.Report.Head}}">Thu</th><th style="{{style $.Report.Head}}">Fri</th></tr>
+{{range .Weeks}}<tr><td style="{{style $.Report.Cell}}">{{.Label}}</td>{{range .Days}}<td style="{{style .Style}}"{{if .Title}} title="{{.Title}}"{{end}}><span style="{{style $.Report.Dim}}">{{.Date}}</span> {{.Text}}</td>{{end}}</tr>
+{{end}}</table>{{end}}{{end}}
+{{template "students" .Report}}
+<p style="{{style .Report.Dim}}">Also as JSON: <a href="/api/report">/api/report</a>, <a href="/api/status">/api/status</a></p>
+</body></html>{{end}}`))
+
+func (d *dashboard) page(snap snapshot, title, current string) servePage {
+	p := servePage{
+		Title:   title,
+		Updated: snap.fetched.Local().Format("Mon Jan 2 at 3:04 PM"),
+		Every:   formatInterval(d.every),
+		Nav:     []serveLink{{Text: "All students", URL: "/", Current: current == ""}},
+	}
+	for i, s := range snap.students {
+		p.Nav = append(p.Nav, serveLink{Text: s.name, URL: "/students/" + snap.slugs[i], Current: current == snap.slugs[i]})
+	}
+	if snap.err != nil {
+		p.Error = snap.err.Error()
+		p.ErrorTime = snap.errorTime.Local().Format("3:04 PM")
+	}
+	return p
+}
+
+func (d *dashboard) render(w http.ResponseWriter, p servePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := servePageTemplate.ExecuteTemplate(w, "page", p); err != nil {
+		fmt.Fprintf(os.Stderr, "Rendering %s: %v\n", p.Title, err)
+	}
+}
+
+func (d *dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	snap := d.current()
+	p := d.page(snap, "Canvas report", "")
+	p.Report = newHTMLData(snap.students, snap.fetched)
+	p.Calendar = weekCalendar(snap.students, len(snap.students) > 1)
+	p.Workload = newWorkloadHTML(snap.students, defaultWorkloadWeeks, len(snap.students) > 1, time.Now())
+	for i, s := range snap.students {
+		st := newCheckStatus(s)
+		p.Statuses = append(p.Statuses, serveLink{Text: st.line(true), URL: "/students/" + snap.slugs[i]})
+	}
+	if len(snap.students) == 0 {
+		p.Statuses = []serveLink{{Text: noStudentsMessage, URL: "/"}}
+	}
+	d.render(w, p)
+}
+
+func (d *dashboard) handleStudent(w http.ResponseWriter, r *http.Request) {
+	snap := d.current()
+	slug := r.PathValue("slug")
+	student, ok := snap.find(slug)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	p := d.page(snap, student.name, slug)
+	p.Report = newHTMLData([]studentData{student}, snap.fetched)
+	p.Calendar = weekCalendar([]studentData{student}, false)
+	p.Workload = newWorkloadHTML([]studentData{student}, defaultWorkloadWeeks, false, time.Now())
+	d.render(w, p)
+}
+
+func (d *dashboard) handleAPIReport(w http.ResponseWriter, r *http.Request) {
+	snap := d.current()
+	report := reportJSON(snap.students)
+	report.GeneratedAt = snap.fetched
+	d.writeAPI(w, snap, report)
+}
+
+func (d *dashboard) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	snap := d.current()
+	statuses := []checkStatus{}
+	for _, s := range snap.students {
+		statuses = append(statuses, newCheckStatus(s))
+	}
+	d.writeAPI(w, snap, statuses)
+}
+
+func (d *dashboard) handleAPIStudent(w http.ResponseWriter, r *http.Request) {
+	snap := d.current()
+	student, ok := snap.find(r.PathValue("slug"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	d.writeAPI(w, snap, studentJSON(student))
+}
+
+// writeAPI writes v as JSON, with headers saying how fresh it is.
+func (d *dashboard) writeAPI(w http.ResponseWriter, snap snapshot, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", snap.fetched.UTC().Format(http.TimeFormat))
+	if snap.err != nil {
+		w.Header().Set("X-Refresh-Error", snap.err.Error())
+	}
+	if err := writeJSON(w, v); err != nil {
+		fmt.Fprintf(os.Stderr, "Writing JSON: %v\n", err)
+	}
+}
+
+// weekCalendar lays out the rest of the school week, one entry per day,
+// from the students' due today/tomorrow and week ahead sections.
+func weekCalendar(students []studentData, withNames bool) []calendarDay {
+	today := truncateToDay(time.Now())
+	end := endOfSchoolWeek(today)
+
+	var days []calendarDay
+	index := make(map[time.Time]int)
+	for day := today; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		index[day] = len(days)
+		days = append(days, calendarDay{Label: day.Format("Mon Jan 2"), Today: day.Equal(today)})
+	}
+
+	for _, s := range students {
+		for _, kind := range []string{sectionUpcoming, sectionWeekAhead} {
+			sec := s.section(kind)
+			if sec == nil {
+				continue
+			}
+			for _, a := range sec.assignments {
+				i, ok := index[truncateToDay(a.DueAt.Local())]
+				if !ok {
+					continue
+				}
+				item := calendarItem{Course: a.CourseName, Name: a.Name, URL: a.URL, Done: isCompleted(a.Submission)}
+				if withNames {
+					item.Student = s.name
+				}
+				days[i].Items = append(days[i].Items, item)
+			}
+		}
+	}
+	return days
+}
+
+// formatInterval drops a duration's zero units, e.g. "15m" rather than "15m0s".
+func formatInterval(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
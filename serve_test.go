@@ -0,0 +1,66 @@
+// ABOUTME: Tests for the canvas-report web dashboard.
+// ABOUTME: Checks the password gate and which listen addresses count as this computer only.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"localhost:8080", true},
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"192.168.1.10:8080", false},
+		{"myhost:8080", false},
+		{"localhost", false}, // No port; ListenAndServe would reject it anyway
+	}
+	for _, tt := range tests {
+		if got := isLoopback(tt.addr); got != tt.want {
+			t.Errorf("isLoopback(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestRequirePassword(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("report"))
+	})
+	handler := requirePassword(ok, "s3cret")
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		noAuth   bool
+		want     int
+	}{
+		{"no credentials", "", "", true, http.StatusUnauthorized},
+		{"wrong password", "grandma", "guess", false, http.StatusUnauthorized},
+		{"empty password", "grandma", "", false, http.StatusUnauthorized},
+		{"right password", "grandma", "s3cret", false, http.StatusOK},
+		{"any user name", "", "s3cret", false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/report", nil)
+		if !tt.noAuth {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header, so browsers won't prompt", tt.name)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// ABOUTME: HTTP request tracing for canvas-report.
+// ABOUTME: Logs each Canvas request for --verbose/--trace and summarizes time spent per student, course and endpoint.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tracer logs Canvas requests and accumulates timings. A nil *tracer is
+// valid and does nothing, so callers never need to check.
+type tracer struct {
+	w      io.Writer
+	detail bool // --trace: also log query strings and headers
+	start  time.Time
+
+	mu          sync.Mutex
+	requests    int
+	total       time.Duration
+	endpoints   map[string]*timing
+	courses     map[int]*timing
+	courseNames map[int]string
+	students    []studentTiming
+}
+
+type timing struct {
+	requests int
+	pages    int
+	bytes    int64
+	elapsed  time.Duration
+}
+
+type studentTiming struct {
+	name    string
+	elapsed time.Duration
+}
+
+func newTracer(w io.Writer, detail bool) *tracer {
+	return &tracer{
+		w:           w,
+		detail:      detail,
+		start:       time.Now(),
+		endpoints:   make(map[string]*timing),
+		courses:     make(map[int]*timing),
+		courseNames: make(map[int]string),
+	}
+}
+
+var (
+	courseIDPattern = regexp.MustCompile(`/courses/(\d+)`)
+	numericSegment  = regexp.MustCompile(`/\d+(/|$)`)
+)
+
+// endpointName collapses IDs in a path so requests to the same API group
+// together, e.g. /api/v1/courses/:id/assignments.
+func endpointName(path string) string {
+	for numericSegment.MatchString(path) {
+		path = numericSegment.ReplaceAllString(path, "/:id$1")
+	}
+	return path
+}
+
+// traceBody counts the bytes read from a response and records the request
+// when the body is closed, so latency includes reading it.
+type traceBody struct {
+	io.ReadCloser
+	n      int64
+	closed bool
+	done   func(n int64)
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *traceBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.closed {
+		b.closed = true
+		b.done(b.n)
+	}
+	return err
+}
+
+// wrap arranges for resp to be logged once its body is read and closed.
+// page is the page number within a paginated walk, or 0 for a single request.
+func (t *tracer) wrap(resp *http.Response, page int, started time.Time) {
+	if t == nil {
+		return
+	}
+	resp.Body = &traceBody{ReadCloser: resp.Body, done: func(n int64) {
+		t.record(resp, page, n, time.Since(started))
+	}}
+}
+
+// failed logs a request that got no response at all.
+func (t *tracer) failed(req *http.Request, page int, started time.Time, err error) {
+	if t == nil {
+		return
+	}
+	elapsed := time.Since(started)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count(req.URL.Path, page, 0, elapsed)
+	fmt.Fprintf(t.w, "%s %s %s%s -> %v (%s)\n", time.Now().Format("15:04:05.000"), req.Method, req.URL.Path, pageLabel(page), err, formatElapsed(elapsed))
+}
+
+func (t *tracer) record(resp *http.Response, page int, n int64, elapsed time.Duration) {
+	req := resp.Request
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count(req.URL.Path, page, n, elapsed)
+
+	line := fmt.Sprintf("%s %s %s%s -> %d %s %s",
+		time.Now().Format("15:04:05.000"), req.Method, req.URL.Path, pageLabel(page),
+		resp.StatusCode, formatElapsed(elapsed), formatBytes(n))
+	if v := resp.Header.Get("X-Rate-Limit-Remaining"); v != "" {
+		line += " rate-remaining=" + v
+	}
+	if v := resp.Header.Get("X-Request-Cost"); v != "" {
+		line += " cost=" + v
+	}
+	fmt.Fprintln(t.w, line)
+
+	if t.detail {
+		if req.URL.RawQuery != "" {
+			fmt.Fprintf(t.w, "    query: %s\n", req.URL.RawQuery)
+		}
+		for _, name := range sortedHeaderNames(resp.Header) {
+			fmt.Fprintf(t.w, "    < %s: %s\n", name, strings.Join(resp.Header[name], ", "))
+		}
+	}
+}
+
+// count adds one request to the totals. Callers hold t.mu.
+func (t *tracer) count(path string, page int, n int64, elapsed time.Duration) {
+	t.requests++
+	t.total += elapsed
+
+	add := func(tm *timing) {
+		if page <= 1 {
+			tm.requests++
+		}
+		tm.pages++
+		tm.bytes += n
+		tm.elapsed += elapsed
+	}
+
+	ep := endpointName(path)
+	if t.endpoints[ep] == nil {
+		t.endpoints[ep] = &timing{}
+	}
+	add(t.endpoints[ep])
+
+	if m := courseIDPattern.FindStringSubmatch(path); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		if t.courses[id] == nil {
+			t.courses[id] = &timing{}
+		}
+		add(t.courses[id])
+	}
+}
+
+// logsTo reports whether the trace is written to w, e.g. so progress
+// spinners on stderr can get out of the way.
+func (t *tracer) logsTo(w io.Writer) bool {
+	return t != nil && t.w == w
+}
+
+// nameCourse labels a course ID in the summary.
+func (t *tracer) nameCourse(id int, name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.courseNames[id] = name
+}
+
+// timeStudent records how long one student's data took to fetch.
+func (t *tracer) timeStudent(name string, started time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.students = append(t.students, studentTiming{name: name, elapsed: time.Since(started)})
+}
+
+// printSummary writes the timing breakdown, slowest first.
+func (t *tracer) printSummary() {
+	if t == nil || t.requests == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintln(t.w)
+	fmt.Fprintf(t.w, "Timing: %d requests, %s in requests, %s elapsed\n",
+		t.requests, formatElapsed(t.total), formatElapsed(time.Since(t.start)))
+
+	if len(t.students) > 0 {
+		fmt.Fprintln(t.w, "  Students:")
+		for _, s := range t.students {
+			fmt.Fprintf(t.w, "    %-40s %8s\n", truncateString(s.name, 40), formatElapsed(s.elapsed))
+		}
+	}
+
+	if len(t.courses) > 0 {
+		fmt.Fprintln(t.w, "  Courses (time in requests):")
+		ids := make([]int, 0, len(t.courses))
+		for id := range t.courses {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return t.courses[ids[i]].elapsed > t.courses[ids[j]].elapsed })
+		for _, id := range ids {
+			name := t.courseNames[id]
+			if name == "" {
+				name = fmt.Sprintf("course %d", id)
+			}
+			fmt.Fprintf(t.w, "    %-40s %8s  %s\n", truncateString(name, 40), formatElapsed(t.courses[id].elapsed), t.courses[id].counts())
+		}
+	}
+
+	fmt.Fprintln(t.w, "  Endpoints:")
+	names := make([]string, 0, len(t.endpoints))
+	for name := range t.endpoints {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return t.endpoints[names[i]].elapsed > t.endpoints[names[j]].elapsed })
+	for _, name := range names {
+		fmt.Fprintf(t.w, "    %-40s %8s  %s\n", name, formatElapsed(t.endpoints[name].elapsed), t.endpoints[name].counts())
+	}
+}
+
+func (tm *timing) counts() string {
+	s := fmt.Sprintf("%d request(s)", tm.requests)
+	if tm.pages > tm.requests {
+		s += fmt.Sprintf(", %d pages", tm.pages)
+	}
+	return s + ", " + formatBytes(tm.bytes)
+}
+
+func pageLabel(page int) string {
+	if page == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" page %d", page)
+}
+
+func formatElapsed(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
+
+func sortedHeaderNames(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,735 @@
+// ABOUTME: Full-screen interactive terminal UI for canvas-report.
+// ABOUTME: Navigates students, sections and courses, and drills into assignment details fetched on demand.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// tuiStudent is one student's data and the report (and so the Canvas
+// client) it came from.
+type tuiStudent struct {
+	report  *Report
+	data    studentData
+	courses []string // Course names for the course filter, sorted
+}
+
+// tuiRow is one line of the list view. Only assignment and grade rows can
+// be selected.
+type tuiRow struct {
+	text       string
+	style      *color.Color
+	header     bool
+	assignment *EnrichedAssignment
+	grade      *CourseGrade
+}
+
+// tuiDetail is the drill-down view of one row.
+type tuiDetail struct {
+	title  string
+	lines  []string
+	url    string
+	offset int
+}
+
+type tui struct {
+	students []tuiStudent
+	student  int
+	course   int // 0 shows every course; otherwise an index into courses, plus one
+	cursor   int // Index into rows; always a selectable row when there is one
+	offset   int // First row shown
+
+	detail  *tuiDetail
+	details map[string]*tuiDetail // Fetched details, by assignment
+
+	width, height int
+	message       string // One-off status shown in the footer
+	out           *bufio.Writer
+}
+
+func runTUI(c *cli, args []string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("tui needs an interactive terminal; use report for scripts")
+	}
+	reports, err := c.loadReports(args, nil)
+	if err != nil {
+		return err
+	}
+
+	t := &tui{details: make(map[string]*tuiDetail), out: bufio.NewWriter(os.Stdout)}
+	for _, r := range reports {
+		collected, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		for _, data := range collected {
+			t.students = append(t.students, tuiStudent{report: r, data: data, courses: courseNames(data)})
+		}
+	}
+	if len(t.students) == 0 {
+		fmt.Println(noStudentsMessage)
+		return nil
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	// Alternate screen, hidden cursor; undone on the way out
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	t.cursor = t.firstSelectable(0, 1)
+	buf := make([]byte, 16)
+	for {
+		t.render()
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		if quit := t.handleKey(string(buf[:n])); quit {
+			return nil
+		}
+	}
+}
+
+// courseNames lists the courses that appear anywhere in a student's data.
+func courseNames(data studentData) []string {
+	seen := make(map[string]bool)
+	for _, sec := range data.sections {
+		for _, a := range sec.assignments {
+			seen[a.CourseName] = true
+		}
+	}
+	for _, pg := range data.grades {
+		for _, g := range pg.grades {
+			seen[g.CourseName] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *tui) current() *tuiStudent {
+	return &t.students[t.student]
+}
+
+// courseFilter returns the selected course name, or "" for all courses.
+func (t *tui) courseFilter() string {
+	if t.course == 0 {
+		return ""
+	}
+	return t.current().courses[t.course-1]
+}
+
+// rows builds the list view for the current student and course filter.
+func (t *tui) rows() []tuiRow {
+	s := t.current()
+	course := t.courseFilter()
+	dim := color.New(color.Faint)
+	headers := map[string]*color.Color{
+		sectionMissing:     color.New(color.FgRed, color.Bold),
+		sectionUnconfirmed: color.New(color.Faint, color.Bold),
+		sectionUpcoming:    color.New(color.FgYellow, color.Bold),
+		sectionWeekAhead:   color.New(color.FgCyan, color.Bold),
+	}
+
+	var rows []tuiRow
+	for _, sec := range s.data.sections {
+		var items []tuiRow
+		for i := range sec.assignments {
+			a := &sec.assignments[i]
+			if course != "" && a.CourseName != course {
+				continue
+			}
+			items = append(items, tuiRow{text: t.assignmentLine(*a, sec.kind), assignment: a})
+		}
+		rows = append(rows, tuiRow{text: fmt.Sprintf("%s (%d)", sec.title, len(items)), style: headers[sec.kind], header: true})
+		if len(items) == 0 {
+			rows = append(rows, tuiRow{text: "  Nothing here", style: dim})
+		}
+		rows = append(rows, items...)
+		rows = append(rows, tuiRow{})
+	}
+
+	for _, pg := range s.data.grades {
+		title := pg.period.Title
+		if title == "" {
+			title = "Current Period"
+		}
+		rows = append(rows, tuiRow{text: "GRADES - " + title, style: color.New(color.FgMagenta, color.Bold), header: true})
+		for i := range pg.grades {
+			g := &pg.grades[i]
+			if course != "" && g.CourseName != course {
+				continue
+			}
+			rows = append(rows, tuiRow{text: fmt.Sprintf("  %s  %.2f%%", fitWidth(stripControl(g.CourseName), 40), g.Percent), grade: g})
+		}
+		rows = append(rows, tuiRow{})
+	}
+
+	if len(s.data.problems) > 0 {
+		rows = append(rows, tuiRow{text: fmt.Sprintf("DATA PROBLEMS (%d)", len(s.data.problems)), style: color.New(color.FgYellow, color.Bold), header: true})
+		for _, p := range s.data.problems {
+			rows = append(rows, tuiRow{text: stripControl(fmt.Sprintf("  %s: %s", p.where(), p.kind())), style: dim})
+		}
+	}
+	return rows
+}
+
+// assignmentLine formats an assignment row to the terminal width.
+func (t *tui) assignmentLine(a EnrichedAssignment, kind string) string {
+	due := strings.ToLower(a.DueAt.Local().Format("Mon 1/2 3pm"))
+	pts := ""
+	if a.PointsPossible != nil {
+		pts = fmt.Sprintf("%d pts", int(*a.PointsPossible))
+	}
+	mark := " "
+	switch {
+	case kind == sectionMissing || kind == sectionUnconfirmed:
+		mark = "✗"
+	case submittedLate(a.Submission):
+		mark = "L"
+	case isCompleted(a.Submission):
+		mark = "✓"
+	}
+
+	courseWidth := 20
+	fixed := 2 + courseWidth + 2 + 2 + 14 + 8 + 14 + 3
+	nameWidth := max(t.width-fixed, 12)
+	return fmt.Sprintf("  %s  %s  %-14s %7s %13s  %s",
+		fitWidth(stripControl(a.CourseName), courseWidth), fitWidth(stripControl(a.Name), nameWidth), due, pts, formatImpact(a.Impact), mark)
+}
+
+func selectable(r tuiRow) bool {
+	return r.assignment != nil || r.grade != nil
+}
+
+// firstSelectable finds the nearest selectable row from i in direction
+// dir, or -1.
+func (t *tui) firstSelectable(i, dir int) int {
+	rows := t.rows()
+	for ; i >= 0 && i < len(rows); i += dir {
+		if selectable(rows[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// handleKey applies one keypress and reports whether to quit.
+func (t *tui) handleKey(key string) bool {
+	t.message = ""
+	if t.detail != nil {
+		return t.handleDetailKey(key)
+	}
+
+	switch key {
+	case "q", "\x03":
+		return true
+	case "\x1b[A", "k":
+		t.move(-1)
+	case "\x1b[B", "j":
+		t.move(1)
+	case "\x1b[5~":
+		t.move(-(t.height - 4))
+	case "\x1b[6~":
+		t.move(t.height - 4)
+	case "\x1b[D", "h":
+		t.switchStudent(-1)
+	case "\x1b[C", "l":
+		t.switchStudent(1)
+	case "\t":
+		t.jumpSection(1)
+	case "\x1b[Z":
+		t.jumpSection(-1)
+	case "c":
+		t.switchCourse(1)
+	case "C":
+		t.switchCourse(-1)
+	case "\r", "\n":
+		t.openDetail()
+	case "o":
+		if rows := t.rows(); t.cursor >= 0 {
+			t.openURL(t.rowURL(rows[t.cursor]))
+		}
+	}
+	return false
+}
+
+func (t *tui) handleDetailKey(key string) bool {
+	switch key {
+	case "\x03":
+		return true
+	case "q", "\x1b", "\x7f", "\x1b[D", "h":
+		t.detail = nil
+	case "\x1b[A", "k":
+		t.detail.offset = max(t.detail.offset-1, 0)
+	case "\x1b[B", "j":
+		t.detail.offset = min(t.detail.offset+1, max(len(t.detail.lines)-(t.height-4), 0))
+	case "\x1b[5~":
+		t.detail.offset = max(t.detail.offset-(t.height-4), 0)
+	case "\x1b[6~", " ":
+		t.detail.offset = min(t.detail.offset+t.height-4, max(len(t.detail.lines)-(t.height-4), 0))
+	case "o":
+		t.openURL(t.detail.url)
+	}
+	return false
+}
+
+func (t *tui) move(delta int) {
+	if t.cursor < 0 {
+		return
+	}
+	dir := 1
+	if delta < 0 {
+		dir = -1
+	}
+	rows := t.rows()
+	next := t.cursor
+	for steps := 0; steps != delta; steps += dir {
+		candidate := t.firstSelectable(next+dir, dir)
+		if candidate < 0 {
+			break
+		}
+		next = candidate
+	}
+	if next == t.cursor && dir < 0 {
+		t.offset = 0 // Already at the top; show the headers above
+	}
+	t.cursor = min(next, len(rows)-1)
+}
+
+func (t *tui) switchStudent(delta int) {
+	t.student = (t.student + delta + len(t.students)) % len(t.students)
+	t.course, t.offset = 0, 0
+	t.cursor = t.firstSelectable(0, 1)
+}
+
+func (t *tui) switchCourse(delta int) {
+	n := len(t.current().courses) + 1
+	t.course = (t.course + delta + n) % n
+	t.offset = 0
+	t.cursor = t.firstSelectable(0, 1)
+}
+
+// jumpSection moves the cursor to the first item of the next (or previous)
+// section that has one.
+func (t *tui) jumpSection(dir int) {
+	rows := t.rows()
+	if t.cursor < 0 {
+		return
+	}
+	// Find the header of the cursor's section, then walk headers from there
+	i := t.cursor
+	for i > 0 && !rows[i].header {
+		i--
+	}
+	for i += dir; i >= 0 && i < len(rows); i += dir {
+		if !rows[i].header {
+			continue
+		}
+		if next := t.firstSelectable(i+1, 1); next >= 0 && (next < len(rows)) && t.sectionOf(rows, next) == i {
+			t.cursor = next
+			return
+		}
+	}
+}
+
+// sectionOf returns the index of the header above row i.
+func (t *tui) sectionOf(rows []tuiRow, i int) int {
+	for i > 0 && !rows[i].header {
+		i--
+	}
+	return i
+}
+
+func (t *tui) rowURL(r tuiRow) string {
+	base := t.current().report.client.baseURL
+	switch {
+	case r.assignment != nil:
+		return r.assignment.URL
+	case r.grade != nil:
+		return fmt.Sprintf("%s/courses/%d/grades/%d", base, r.grade.CourseID, t.current().data.id)
+	}
+	return ""
+}
+
+func (t *tui) openURL(url string) {
+	if url == "" {
+		t.message = "No Canvas link for this row"
+		return
+	}
+	if err := openBrowser(url); err != nil {
+		t.message = "Couldn't open a browser: " + err.Error()
+		return
+	}
+	t.message = "Opened " + url
+}
+
+func detailKey(a EnrichedAssignment) string {
+	return fmt.Sprintf("%d/%d", a.CourseID, a.ID)
+}
+
+// openDetail shows the selected row's details, fetching an assignment's
+// description, rubric, submissions and comments the first time.
+func (t *tui) openDetail() {
+	rows := t.rows()
+	if t.cursor < 0 {
+		return
+	}
+	row := rows[t.cursor]
+	switch {
+	case row.grade != nil:
+		t.detail = t.gradeDetail(*row.grade)
+		t.detail.url = t.rowURL(row)
+	case row.assignment != nil:
+		key := detailKey(*row.assignment)
+		if d, ok := t.details[key]; ok {
+			d.offset = 0
+			t.detail = d
+			return
+		}
+		t.message = "Loading " + stripControl(row.assignment.Name) + "..."
+		t.render()
+		t.message = ""
+		d := t.assignmentDetail(*row.assignment)
+		t.details[key] = d
+		t.detail = d
+	}
+}
+
+func (t *tui) gradeDetail(g CourseGrade) *tuiDetail {
+	d := &tuiDetail{title: stripControl(g.CourseName)}
+	d.lines = append(d.lines, fmt.Sprintf("Current grade: %.2f%%", g.Percent))
+	if !g.Weighted {
+		d.lines = append(d.lines, fmt.Sprintf("Points: %.0f of %.0f", g.Points, g.PointsPossible))
+		return d
+	}
+	d.lines = append(d.lines, "", "Weighted categories:")
+	for _, cat := range g.Categories {
+		d.lines = append(d.lines, fmt.Sprintf("  %-28s %7.2f%%  %4.0f/%-4.0f  weight %.0f%%",
+			fitWidth(stripControl(cat.Name), 28), cat.Percent, cat.Points, cat.PointsPossible, cat.Weight))
+	}
+	return d
+}
+
+func (t *tui) assignmentDetail(a EnrichedAssignment) *tuiDetail {
+	s := t.current()
+	client := s.report.client
+	// Everything Canvas sends was typed by someone else, so none of it
+	// gets to write control characters to the terminal
+	a.Name, a.CourseName, a.CategoryName = stripControl(a.Name), stripControl(a.CourseName), stripControl(a.CategoryName)
+	d := &tuiDetail{title: a.Name, url: a.URL}
+	add := func(lines ...string) { d.lines = append(d.lines, lines...) }
+	wrapWidth := max(t.width-4, 20)
+
+	add(fmt.Sprintf("Course:   %s", a.CourseName))
+	if a.CategoryName != "" {
+		add(fmt.Sprintf("Category: %s", a.CategoryName))
+	}
+	add(fmt.Sprintf("Due:      %s", a.DueAt.Local().Format("Mon Jan 2, 2006 at 3:04 PM")))
+	if a.PointsPossible != nil {
+		add(fmt.Sprintf("Points:   %g", *a.PointsPossible))
+	}
+	if a.Status != "" {
+		add(fmt.Sprintf("Status:   %s", a.Status))
+	}
+	if submittedLate(a.Submission) {
+		line := "Late:     turned in " + formatLateness(a.Submission.SecondsLate)
+		if deduction := formatDeduction(a.Submission); deduction != "" {
+			line += ", " + deduction
+		}
+		add(line)
+	}
+
+	add("", "IMPACT")
+	if a.Impact == nil {
+		add("  No grade impact calculated for this assignment.")
+	} else {
+		if a.Impact.Gain >= 0.05 {
+			add(fmt.Sprintf("  Full credit would raise the course grade by %.1f%%.", a.Impact.Gain))
+		}
+		if a.Impact.Penalty > 0 {
+			add(fmt.Sprintf("  That's after the late policy's %g%% deduction for turning it in now.", a.Impact.Penalty))
+		}
+		if a.Impact.Loss >= 0.05 {
+			add(fmt.Sprintf("  A zero would lower it by %.1f%%.", a.Impact.Loss))
+		}
+		if a.CategoryName != "" {
+			add(fmt.Sprintf("  Counted in the %s category.", a.CategoryName))
+		}
+	}
+
+	detail, err := client.AssignmentDetail(a.CourseID, a.ID)
+	if err != nil {
+		add("", "Couldn't load the assignment: "+err.Error())
+	} else {
+		if text := htmlToText(detail.Description); text != "" {
+			add("", "DESCRIPTION")
+			if utf8.RuneCountInString(text) > 800 {
+				text = string([]rune(text)[:800]) + "…"
+			}
+			for _, para := range strings.Split(text, "\n") {
+				for _, line := range wrapText(para, wrapWidth) {
+					add("  " + line)
+				}
+			}
+		}
+	}
+
+	sub, subErr := client.SubmissionDetail(a.CourseID, a.ID, s.data.id)
+
+	if detail != nil && len(detail.Rubric) > 0 {
+		add("", "RUBRIC")
+		for _, crit := range detail.Rubric {
+			desc := stripControl(crit.Description)
+			line := fmt.Sprintf("  %s (%g pts)", desc, crit.Points)
+			if sub != nil {
+				if assessed, ok := sub.RubricAssessment[crit.ID]; ok && assessed.Points != nil {
+					line = fmt.Sprintf("  %s: %g/%g", desc, *assessed.Points, crit.Points)
+				}
+			}
+			add(line)
+			for _, rating := range crit.Ratings {
+				add(fmt.Sprintf("    %4g  %s", rating.Points, stripControl(rating.Description)))
+			}
+			if sub != nil && sub.RubricAssessment[crit.ID].Comments != "" {
+				for _, l := range wrapText(stripControl(sub.RubricAssessment[crit.ID].Comments), wrapWidth-4) {
+					add("    > " + l)
+				}
+			}
+		}
+	}
+
+	if subErr != nil {
+		add("", "Couldn't load the submission: "+subErr.Error())
+		return d
+	}
+
+	add("", "SUBMISSIONS")
+	history := sub.SubmissionHistory
+	if len(history) == 0 && sub.SubmittedAt != nil {
+		history = []SubmissionDetail{*sub}
+	}
+	if len(history) == 0 {
+		add("  Nothing submitted.")
+	}
+	for _, h := range history {
+		if h.SubmittedAt == nil {
+			continue
+		}
+		line := "  Attempt"
+		if h.Attempt != nil {
+			line += fmt.Sprintf(" %d", *h.Attempt)
+		}
+		line += ": " + h.SubmittedAt.Local().Format("Mon Jan 2 3:04 PM")
+		if h.Late {
+			line += " (late)"
+		}
+		if h.Score != nil {
+			line += fmt.Sprintf(", score %g", *h.Score)
+		}
+		add(line)
+	}
+	switch {
+	case sub.Excused:
+		add("  Excused.")
+	case sub.Score != nil:
+		add(fmt.Sprintf("  Current score: %g", *sub.Score))
+	case sub.Missing:
+		add("  Marked missing.")
+	}
+
+	add("", "COMMENTS")
+	if len(sub.SubmissionComments) == 0 {
+		add("  No comments.")
+	}
+	for _, c := range sub.SubmissionComments {
+		add(fmt.Sprintf("  %s, %s:", stripControl(c.AuthorName), c.CreatedAt.Local().Format("Mon Jan 2 3:04 PM")))
+		for _, l := range wrapText(stripControl(c.Comment), wrapWidth-2) {
+			add("    " + l)
+		}
+	}
+	return d
+}
+
+// render redraws the whole screen.
+func (t *tui) render() {
+	t.width, t.height = 80, 24
+	if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		t.width, t.height = w, h
+	}
+
+	var lines []string
+	var footer string
+	if t.detail != nil {
+		lines, footer = t.renderDetail()
+	} else {
+		lines, footer = t.renderList()
+	}
+
+	t.out.WriteString("\x1b[H")
+	for i := 0; i < t.height-1; i++ {
+		if i < len(lines) {
+			t.out.WriteString(lines[i])
+		}
+		t.out.WriteString("\x1b[K\r\n")
+	}
+	if t.message != "" {
+		footer = t.message
+	}
+	t.out.WriteString(color.New(color.Faint).Sprint(fitWidth(footer, t.width-1)) + "\x1b[K")
+	t.out.Flush()
+}
+
+func (t *tui) renderList() ([]string, string) {
+	var lines []string
+
+	// Student tabs, the current one highlighted
+	var tabs []string
+	for i, s := range t.students {
+		label := " " + stripControl(s.data.name) + " "
+		if i == t.student {
+			label = "\x1b[7m" + label + "\x1b[0m"
+		}
+		tabs = append(tabs, label)
+	}
+	lines = append(lines, strings.Join(tabs, " "))
+	course := t.courseFilter()
+	if course == "" {
+		course = "All courses"
+	}
+	lines = append(lines, color.New(color.Faint).Sprint("Course: ")+stripControl(course), "")
+
+	rows := t.rows()
+	visible := t.height - 1 - len(lines)
+	if t.cursor >= 0 {
+		if t.cursor < t.offset {
+			t.offset = t.cursor
+		}
+		if t.cursor >= t.offset+visible {
+			t.offset = t.cursor - visible + 1
+		}
+	}
+	t.offset = max(min(t.offset, len(rows)-visible), 0)
+
+	for i := t.offset; i < len(rows) && i < t.offset+visible; i++ {
+		r := rows[i]
+		text := fitWidth(r.text, t.width)
+		switch {
+		case i == t.cursor:
+			text = "\x1b[7m" + text + "\x1b[0m"
+		case r.style != nil:
+			text = r.style.Sprint(text)
+		}
+		lines = append(lines, text)
+	}
+	return lines, "↑↓ move  ←→ student  tab section  c course  enter details  o open in Canvas  q quit"
+}
+
+func (t *tui) renderDetail() ([]string, string) {
+	d := t.detail
+	lines := []string{color.New(color.Bold).Sprint(fitWidth(d.title, t.width)), ""}
+	visible := t.height - 1 - len(lines)
+	for i := d.offset; i < len(d.lines) && i < d.offset+visible; i++ {
+		text := fitWidth(d.lines[i], t.width)
+		if text != "" && text == strings.ToUpper(text) && !strings.HasPrefix(text, " ") {
+			text = color.New(color.Bold).Sprint(text)
+		}
+		lines = append(lines, text)
+	}
+	footer := "↑↓ scroll  o open in Canvas  esc back"
+	if d.url != "" {
+		footer += "  " + d.url
+	}
+	return lines, footer
+}
+
+// fitWidth pads or truncates s to exactly width runes, marking a cut with
+// an ellipsis.
+func fitWidth(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if n := utf8.RuneCountInString(s); n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+var (
+	htmlBreaks  = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>|</h\d>`)
+	htmlTags    = regexp.MustCompile(`<[^>]*>`)
+	blankSpaces = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankLines  = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// htmlToText reduces an assignment description to plain paragraphs.
+func htmlToText(s string) string {
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = stripControl(s)
+	s = blankSpaces.ReplaceAllString(s, " ")
+	s = blankLines.ReplaceAllString(s, "\n")
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripControl drops C0 and C1 control characters other than newlines and
+// tabs, so text from Canvas can't send escape sequences to the terminal,
+// even ones written in a description as entities like &#27;.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// wrapText breaks s into lines of at most width runes at spaces.
+func wrapText(s string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,377 @@
+// ABOUTME: Workload forecast for canvas-report.
+// ABOUTME: Buckets upcoming assignments by school day and shows the weeks ahead as a heatmap of count, points and impact.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const defaultWorkloadWeeks = 3
+
+func workloadFlags(c *cli, fs *flag.FlagSet) {
+	fs.IntVar(&c.workloadWeeks, "weeks", defaultWorkloadWeeks, "forecast `n` school weeks, starting with this one")
+	strictFlag(c, fs)
+}
+
+// workloadDay is what's due on one school day. Weekend due dates count
+// toward the Monday after.
+type workloadDay struct {
+	date        time.Time
+	past        bool // Earlier this week, before today
+	count       int
+	points      float64
+	impact      float64 // Sum of the gains still available
+	assignments []EnrichedAssignment
+}
+
+// workloadWeek is Monday through Friday.
+type workloadWeek [5]workloadDay
+
+// Points at which a day reaches each heat level; below the first is light.
+var workloadThresholds = []float64{25, 50, 100}
+
+func (d workloadDay) level() int {
+	if d.past || d.count == 0 {
+		return 0
+	}
+	level := 1
+	for _, t := range workloadThresholds {
+		if d.points >= t {
+			level++
+		}
+	}
+	return level
+}
+
+// schoolDayOf returns the school day an assignment counts toward.
+func schoolDayOf(t time.Time) time.Time {
+	day := truncateToDay(t.Local())
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return nextSchoolDay(day)
+	}
+	return day
+}
+
+// buildWorkload buckets assignments into the given number of school weeks,
+// starting with the week of the next school day. Excused work and work due
+// before today are left out.
+func buildWorkload(assignments []EnrichedAssignment, weeks int, now time.Time) []workloadWeek {
+	// Due dates are bucketed in local time, so the grid has to be too
+	now = now.Local()
+	today := truncateToDay(now)
+	first := schoolDayOf(today)
+	monday := first.AddDate(0, 0, -int(first.Weekday()-time.Monday))
+
+	result := make([]workloadWeek, weeks)
+	index := make(map[string]*workloadDay) // Keyed by date, like 2026-10-19
+	for w := range result {
+		for d := range result[w] {
+			date := monday.AddDate(0, 0, 7*w+d)
+			result[w][d] = workloadDay{date: date, past: date.Before(today)}
+			index[date.Format("2006-01-02")] = &result[w][d]
+		}
+	}
+
+	for _, a := range assignments {
+		if a.DueAt.Before(today) || (a.Submission != nil && a.Submission.Excused) {
+			continue
+		}
+		day := index[schoolDayOf(a.DueAt).Format("2006-01-02")]
+		if day == nil || day.past {
+			continue
+		}
+		day.count++
+		day.points += max(pointsOrNone(a), 0)
+		if a.Impact != nil && !isCompleted(a.Submission) {
+			day.impact += max(a.Impact.Gain, 0)
+		}
+		day.assignments = append(day.assignments, a)
+	}
+	return result
+}
+
+// busiestDays returns up to n days with work due, heaviest first.
+func busiestDays(weeks []workloadWeek, n int) []workloadDay {
+	var days []workloadDay
+	for _, week := range weeks {
+		for _, d := range week {
+			if d.count > 0 {
+				days = append(days, d)
+			}
+		}
+	}
+	sort.SliceStable(days, func(i, j int) bool {
+		if days[i].points != days[j].points {
+			return days[i].points > days[j].points
+		}
+		return days[i].count > days[j].count
+	})
+	if len(days) > n {
+		days = days[:n]
+	}
+	return days
+}
+
+// cellText is a day's summary, like "3 · 95 pts".
+func (d workloadDay) cellText() string {
+	switch {
+	case d.past:
+		return ""
+	case d.count == 0:
+		return "-"
+	}
+	return fmt.Sprintf("%d · %g pts", d.count, d.points)
+}
+
+func runWorkload(c *cli, args []string) error {
+	if c.workloadWeeks < 1 {
+		return &exitCodeError{code: exitUsage, err: fmt.Errorf("--weeks must be at least 1, got %d", c.workloadWeeks)}
+	}
+	reports, err := c.loadReports(args, assignmentSections)
+	if err != nil {
+		return err
+	}
+	var students []studentData
+	for _, r := range reports {
+		collected, err := r.Collect()
+		if err != nil {
+			return err
+		}
+		students = append(students, collected...)
+	}
+	if len(students) == 0 {
+		fmt.Println(noStudentsMessage)
+		return nil
+	}
+
+	now := time.Now()
+	problems := 0
+	for _, data := range students {
+		problems += len(data.problems)
+	}
+
+	switch c.format {
+	case "json":
+		out := []jsonWorkload{}
+		for _, data := range students {
+			out = append(out, workloadJSON(data, buildWorkload(data.assignments, c.workloadWeeks, now)))
+		}
+		if err := writeJSON(os.Stdout, out); err != nil {
+			return err
+		}
+	case "markdown":
+		for i, data := range students {
+			if i > 0 {
+				fmt.Println()
+			}
+			printWorkloadMarkdown(os.Stdout, data, buildWorkload(data.assignments, c.workloadWeeks, now))
+		}
+	default:
+		for _, data := range students {
+			printWorkload(os.Stdout, data, buildWorkload(data.assignments, c.workloadWeeks, now))
+		}
+	}
+	return reports[0].checkStrict(problems)
+}
+
+// Terminal colors for each heat level.
+var workloadColors = []*color.Color{
+	color.New(color.Faint),
+	color.New(color.FgBlack, color.BgGreen),
+	color.New(color.FgBlack, color.BgYellow),
+	color.New(color.FgWhite, color.BgRed),
+	color.New(color.FgWhite, color.BgMagenta, color.Bold),
+}
+
+const workloadCellWidth = 17
+
+func printWorkload(w io.Writer, data studentData, weeks []workloadWeek) {
+	printHeader(w, data.name)
+	color.New(color.FgCyan, color.Bold).Fprintf(w, "WORKLOAD - NEXT %d WEEKS\n", len(weeks))
+
+	fmt.Fprintf(w, "%-8s", "")
+	for _, name := range []string{"Mon", "Tue", "Wed", "Thu", "Fri"} {
+		fmt.Fprintf(w, " %-*s", workloadCellWidth, name)
+	}
+	fmt.Fprintln(w)
+	for _, week := range weeks {
+		fmt.Fprintf(w, "%-8s", week[0].date.Format("Jan 2"))
+		for _, d := range week {
+			label := fmt.Sprintf("%-5s", d.date.Format("1/2"))
+			if text := d.cellText(); text != "" {
+				label += " " + text
+			}
+			fmt.Fprint(w, " ", workloadColors[d.level()].Sprint(fitWidth(label, workloadCellWidth)))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "\n%8s", "")
+	legend := []string{"none", fmt.Sprintf("<%g pts", workloadThresholds[0])}
+	for i := 1; i < len(workloadThresholds); i++ {
+		legend = append(legend, fmt.Sprintf("%g-%g", workloadThresholds[i-1], workloadThresholds[i]-1))
+	}
+	legend = append(legend, fmt.Sprintf("%g+", workloadThresholds[len(workloadThresholds)-1]))
+	for i, text := range legend {
+		fmt.Fprint(w, " ", workloadColors[i].Sprint(" "+text+" "))
+	}
+	fmt.Fprintln(w)
+
+	busiest := busiestDays(weeks, 3)
+	if len(busiest) > 0 {
+		fmt.Fprintln(w)
+		color.New(color.Bold).Fprintln(w, "BUSIEST DAYS")
+		for _, d := range busiest {
+			line := fmt.Sprintf("  %s: %d due, %g pts", d.date.Format("Mon 1/2"), d.count, d.points)
+			if d.impact >= 0.05 {
+				line += fmt.Sprintf(", up to +%.1f%%", d.impact)
+			}
+			fmt.Fprintln(w, line)
+			for _, a := range d.assignments {
+				fmt.Fprintf(w, "    %s (%s)\n", hyperlink(a.Name, a.URL), hyperlink(a.CourseName, a.CourseURL))
+			}
+		}
+	}
+
+	if len(data.problems) > 0 {
+		fmt.Fprintln(w)
+		printProblems(w, data.problems)
+	}
+}
+
+// Markdown squares for each heat level.
+var workloadEmoji = []string{"⬜", "🟩", "🟨", "🟥", "🟪"}
+
+func printWorkloadMarkdown(w io.Writer, data studentData, weeks []workloadWeek) {
+	fmt.Fprintf(w, "## %s\n\n", mdEscape(data.name))
+	fmt.Fprintln(w, "| Week | Mon | Tue | Wed | Thu | Fri |")
+	fmt.Fprintln(w, "|---|---|---|---|---|---|")
+	for _, week := range weeks {
+		cells := []string{week[0].date.Format("Jan 2")}
+		for _, d := range week {
+			if d.past {
+				cells = append(cells, "")
+				continue
+			}
+			cells = append(cells, workloadEmoji[d.level()]+" "+d.cellText())
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	busiest := busiestDays(weeks, 3)
+	if len(busiest) > 0 {
+		fmt.Fprint(w, "\n### Busiest days\n\n")
+		for _, d := range busiest {
+			var names []string
+			for _, a := range d.assignments {
+				names = append(names, mdLink(a.Name, a.URL))
+			}
+			fmt.Fprintf(w, "- **%s**: %d due, %g pts: %s\n", d.date.Format("Mon 1/2"), d.count, d.points, strings.Join(names, ", "))
+		}
+	}
+
+	if len(data.problems) > 0 {
+		fmt.Fprintf(w, "\n### ⚠️ DATA PROBLEMS (%d)\n\n", len(data.problems))
+		for _, p := range data.problems {
+			fmt.Fprintf(w, "- %s: %s. %s\n", mdEscape(p.where()), mdEscape(p.kind()), mdEscape(p.detail()))
+		}
+	}
+}
+
+// HTML background colors for each heat level.
+var workloadBackgrounds = []string{"#ffffff", "#d5f5e3", "#fcf3cf", "#f5b7b1", "#d7bde2"}
+
+// workloadHTML is one student's heatmap for the dashboard.
+type workloadHTML struct {
+	Name  string // Empty on a single student's page
+	Weeks []workloadHTMLWeek
+}
+
+type workloadHTMLWeek struct {
+	Label string
+	Days  []workloadHTMLDay
+}
+
+type workloadHTMLDay struct {
+	Date, Text, Title, Style string
+}
+
+func newWorkloadHTML(students []studentData, weeks int, withNames bool, now time.Time) []workloadHTML {
+	var out []workloadHTML
+	for _, data := range students {
+		wh := workloadHTML{}
+		if withNames {
+			wh.Name = data.name
+		}
+		for _, week := range buildWorkload(data.assignments, weeks, now) {
+			hw := workloadHTMLWeek{Label: week[0].date.Format("Jan 2")}
+			for _, d := range week {
+				day := workloadHTMLDay{
+					Date:  d.date.Format("1/2"),
+					Text:  d.cellText(),
+					Style: htmlCell + ";min-width:90px;background:" + workloadBackgrounds[d.level()],
+				}
+				if d.past {
+					day.Style += ";" + htmlDim
+				}
+				var names []string
+				for _, a := range d.assignments {
+					names = append(names, a.Name+" ("+a.CourseName+")")
+				}
+				day.Title = strings.Join(names, "\n")
+				hw.Days = append(hw.Days, day)
+			}
+			wh.Weeks = append(wh.Weeks, hw)
+		}
+		out = append(out, wh)
+	}
+	return out
+}
+
+type jsonWorkload struct {
+	Name     string            `json:"name"`
+	Days     []jsonWorkloadDay `json:"days"`
+	Problems []jsonProblem     `json:"problems,omitempty"`
+}
+
+type jsonWorkloadDay struct {
+	Date        string           `json:"date"`
+	Count       int              `json:"count"`
+	Points      float64          `json:"points"`
+	Impact      float64          `json:"impact"`
+	Level       int              `json:"level"` // 0 (nothing due) to 4
+	Assignments []jsonAssignment `json:"assignments"`
+}
+
+func workloadJSON(data studentData, weeks []workloadWeek) jsonWorkload {
+	out := jsonWorkload{Name: data.name, Days: []jsonWorkloadDay{}, Problems: problemsJSON(data.problems)}
+	for _, week := range weeks {
+		for _, d := range week {
+			if d.past {
+				continue
+			}
+			jd := jsonWorkloadDay{
+				Date:        d.date.Format("2006-01-02"),
+				Count:       d.count,
+				Points:      d.points,
+				Impact:      d.impact,
+				Level:       d.level(),
+				Assignments: []jsonAssignment{},
+			}
+			for _, a := range d.assignments {
+				jd.Assignments = append(jd.Assignments, assignmentJSON(a))
+			}
+			out.Days = append(out.Days, jd)
+		}
+	}
+	return out
+}
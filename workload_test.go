@@ -0,0 +1,108 @@
+// ABOUTME: Tests for the canvas-report workload forecast.
+// ABOUTME: Checks which school day each assignment lands on, including weekends, days already past and excused work.
+
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildWorkload(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, time.Local)
+	}
+	points := func(v float64) *float64 { return &v }
+	// The same moment where it's still the day before
+	west := func(t time.Time) time.Time {
+		_, offset := t.Zone()
+		return t.In(time.FixedZone("west", offset-5*60*60))
+	}
+
+	assignments := []EnrichedAssignment{
+		{Name: "tue", DueAt: at(time.October, 13, 23)},
+		{Name: "wed morning", DueAt: at(time.October, 14, 8), PointsPossible: points(10)},
+		{Name: "fri", DueAt: at(time.October, 16, 23), PointsPossible: points(20)},
+		{Name: "excused", DueAt: at(time.October, 16, 12), PointsPossible: points(50), Submission: &Submission{Excused: true}},
+		{Name: "sat", DueAt: at(time.October, 17, 12), PointsPossible: points(5)},
+		{Name: "sun", DueAt: at(time.October, 18, 23), Impact: &AssignmentImpact{Gain: 1.5}},
+		{Name: "mon", DueAt: at(time.October, 19, 9), PointsPossible: points(15)},
+		{Name: "no points", DueAt: at(time.October, 20, 9)},
+		{Name: "far off", DueAt: at(time.November, 2, 9)},
+	}
+
+	type day struct {
+		names  []string
+		points float64
+		impact float64
+	}
+	tests := []struct {
+		name   string
+		now    time.Time
+		monday time.Time
+		past   int // Days of the first week already gone
+		want   map[string]day
+	}{
+		{
+			name:   "wednesday",
+			now:    at(time.October, 14, 10),
+			monday: at(time.October, 12, 0),
+			past:   2,
+			want: map[string]day{
+				"2026-10-14": {[]string{"wed morning"}, 10, 0},
+				"2026-10-16": {[]string{"fri"}, 20, 0},
+				"2026-10-19": {[]string{"sat", "sun", "mon"}, 20, 1.5},
+				"2026-10-20": {[]string{"no points"}, 0, 0},
+			},
+		},
+		{
+			name:   "saturday rolls over to next week",
+			now:    at(time.October, 17, 2),
+			monday: at(time.October, 19, 0),
+			want: map[string]day{
+				"2026-10-19": {[]string{"sat", "sun", "mon"}, 20, 1.5},
+				"2026-10-20": {[]string{"no points"}, 0, 0},
+			},
+		},
+		{
+			// Still Friday evening in now's zone, but the forecast follows
+			// local time, like the due dates
+			name:   "saturday given in another zone",
+			now:    west(at(time.October, 17, 2)),
+			monday: at(time.October, 19, 0),
+			want: map[string]day{
+				"2026-10-19": {[]string{"sat", "sun", "mon"}, 20, 1.5},
+				"2026-10-20": {[]string{"no points"}, 0, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		weeks := buildWorkload(assignments, 2, tt.now)
+		if len(weeks) != 2 {
+			t.Fatalf("%s: %d weeks, want 2", tt.name, len(weeks))
+		}
+		if got := weeks[0][0].date; !got.Equal(tt.monday) {
+			t.Errorf("%s: forecast starts %s, want %s", tt.name, got.Format("Mon Jan 2"), tt.monday.Format("Mon Jan 2"))
+		}
+		for w, week := range weeks {
+			for i, d := range week {
+				key := d.date.Format("2006-01-02")
+				if past := w == 0 && i < tt.past; d.past != past {
+					t.Errorf("%s: %s past = %v, want %v", tt.name, key, d.past, past)
+				}
+				var names []string
+				for _, a := range d.assignments {
+					names = append(names, a.Name)
+				}
+				want := tt.want[key]
+				if !slices.Equal(names, want.names) || d.count != len(want.names) {
+					t.Errorf("%s: %s has %q (count %d), want %q", tt.name, key, names, d.count, want.names)
+				}
+				if d.points != want.points || d.impact != want.impact {
+					t.Errorf("%s: %s points %g, impact %g; want %g, %g", tt.name, key, d.points, d.impact, want.points, want.impact)
+				}
+			}
+		}
+	}
+}